@@ -17,6 +17,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"getmssql/dbexport"
+	"getmssql/i18n"
 	"os"
 	"os/signal"
 	"strings"
@@ -37,6 +39,7 @@ import (
 //	})
 //
 // If any required environment variable is missing or the connection fails, an error is returned.
+// Errors of a known class (see errorClasses) come back with a hint for the user.
 // sqlOpen and dbPing are package-level variables to allow test injection.
 var sqlOpen = sql.Open
 var dbPing = func(db *sql.DB) error { return db.Ping() }
@@ -83,19 +86,19 @@ func withDB(database string, fn func(ctx context.Context, db *sql.DB) error) err
 		missing = append(missing, "MSSQL_DATABASE")
 	}
 	if len(missing) > 0 {
-		return fmt.Errorf("missing required connection parameters: %s", strings.Join(missing, ", "))
+		return i18n.Errorf("db.missing_params", strings.Join(missing, ", "))
 	}
 	connString := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%s;database=%s;encrypt=disable", server, user, password, port, database)
 	db, err := sqlOpen("sqlserver", connString)
 	if err != nil {
-		return fmt.Errorf("error creating connection pool: %v", err)
+		return i18n.Errorf("db.pool_error", err)
 	}
 	defer db.Close()
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 	if err := dbPing(db); err != nil {
-		return fmt.Errorf("cannot connect to database: %v", err)
+		return withHint(i18n.Errorf("db.connect_error", dbexport.ClassifyError(err)))
 	}
-	fmt.Println("Connected to MSSQL successfully!")
-	return fn(ctx, db)
+	fmt.Println(i18n.T("db.connected"))
+	return withHint(fn(ctx, db))
 }
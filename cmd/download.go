@@ -3,16 +3,51 @@ package cmd
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"getmssql/dbexport"
+	"getmssql/i18n"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	downloadFields   string
-	downloadFormat   string
-	downloadDatabase string
+	downloadFields    string
+	downloadFormat    string
+	downloadDatabase  string
+	downloadOutput    string
+	downloadWhere     string
+	downloadBatchSize int
+	downloadOverwrite string
+	downloadProgress  string
+	downloadRowErrors string
+	downloadRejects   string
+	downloadTypes     string
+	downloadDatetime  string
+	downloadTimezone  string
+	downloadBinary    string
+	downloadTypesFile string
+
+	downloadCSVDialect   string
+	downloadCSVDelimiter string
+	downloadCSVQuote     string
+	downloadCSVQuoteAll  bool
+	downloadCSVLineEnd   string
+	downloadCSVHeader    bool
+	downloadCSVNull      string
+	downloadTSVEscaping  string
+	downloadGzip         bool
+
+	downloadParquetRowGroup    int64
+	downloadParquetCompression string
+	downloadArrowStream        bool
+	downloadSQLDialect         string
+	downloadSQLInsertRows      int
+	downloadSQLTransactions    bool
+	downloadAvroCodec          string
 )
 
 var downloadCmd = &cobra.Command{
@@ -21,25 +56,223 @@ var downloadCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		table := args[0]
-		asTSV := downloadFormat == "tsv"
-		asCSV := downloadFormat == "csv"
-		asSQLite := downloadFormat == "sqlite3"
-		asDuckDB := downloadFormat == "duckdb"
+		overwrite, err := dbexport.ParseOverwritePolicy(downloadOverwrite)
+		if err != nil {
+			return err
+		}
+		types, err := dbexport.ParseTypeMode(downloadTypes)
+		if err != nil {
+			return err
+		}
+		if err := dbexport.ValidateDatetimeFormat(downloadDatetime); err != nil {
+			return err
+		}
+		var timeZone *time.Location
+		if downloadTimezone != "" {
+			if timeZone, err = time.LoadLocation(downloadTimezone); err != nil {
+				return i18n.Errorf("values.bad_timezone", downloadTimezone, err)
+			}
+		}
+		binary, err := dbexport.ParseBinaryEncoding(downloadBinary)
+		if err != nil {
+			return err
+		}
+		csvDialect, err := csvDialectFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		tsvEscaping, err := dbexport.ParseTSVEscaping(downloadTSVEscaping)
+		if err != nil {
+			return err
+		}
+		parquetCompression, err := dbexport.ParseParquetCompression(downloadParquetCompression)
+		if err != nil {
+			return err
+		}
+		avroCodec, err := dbexport.ParseAvroCodec(downloadAvroCodec)
+		if err != nil {
+			return err
+		}
+		sqlDialect, err := dbexport.ParseSQLDialect(downloadSQLDialect)
+		if err != nil {
+			return err
+		}
+		sqlOpts := dbexport.SQLOptions{
+			Dialect:      sqlDialect,
+			InsertRows:   downloadSQLInsertRows,
+			Transactions: downloadSQLTransactions,
+		}
+		if err := sqlOpts.Validate(); err != nil {
+			return err
+		}
+		wopts := dbexport.WriterOptions{
+			Table:          table,
+			Output:         downloadOutput,
+			Overwrite:      overwrite,
+			Types:          types,
+			DatetimeFormat: downloadDatetime,
+			TimeZone:       timeZone,
+			BinaryEncoding: binary,
+			CSV:            csvDialect,
+			TSV:            tsvEscaping,
+			Gzip:           downloadGzip,
+			Arrow:          dbexport.ArrowOptions{Stream: downloadArrowStream},
+			Avro:           dbexport.AvroOptions{Codec: avroCodec},
+			Parquet: dbexport.ParquetOptions{
+				RowGroupSize: downloadParquetRowGroup,
+				Compression:  parquetCompression,
+			},
+			SQL: sqlOpts,
+		}
+		// Resolve the writer up front so an unknown format fails before connecting
+		w, err := dbexport.NewWriter(downloadFormat, wopts)
+		if err != nil {
+			return err
+		}
+		progress, err := newProgressReporter(downloadProgress)
+		if err != nil {
+			return err
+		}
+		onRowError, err := dbexport.ParseRowErrorPolicy(downloadRowErrors)
+		if err != nil {
+			return err
+		}
+		rejectFile := downloadRejects
+		if rejectFile == "" && onRowError != dbexport.RowErrorFail {
+			rejectFile = strings.ToLower(table) + ".rejects.jsonl"
+		}
+		opts := dbexport.ExportOptions{
+			Format:     downloadFormat,
+			Writer:     w,
+			FieldsFile: downloadFields,
+			TypesFile:  downloadTypesFile,
+			Where:      downloadWhere,
+			BatchSize:  downloadBatchSize,
+			Progress:   progress,
+			OnRowError: onRowError,
+			RejectFile: rejectFile,
+			// The file formats get their values converted outside the writer
+			WriterOptions: wopts,
+		}
 		return withDB(downloadDatabase, func(ctx context.Context, db *sql.DB) error {
-			err := dbexport.DownloadTable(db, table, downloadFields, asTSV, asCSV, asSQLite, asDuckDB)
+			res, err := dbexport.DownloadTableContext(ctx, db, table, opts)
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("\n" + i18n.T("cli.interrupted_notice"))
+				return errAborted
+			}
 			if err != nil {
-				if isInvalidTableError(err) {
-					return fmt.Errorf("%v.\n\nverifica que el nombre de la tabla o vista exista en la base de datos y esté correctamente escrito. si pertenece a otro esquema, usa el nombre completo (por ejemplo: esquema.tabla)", err)
-				}
+				return err
 			}
-			return err
+			if !res.Aborted {
+				fmt.Println(i18n.T("download.done", res.Table, res.Output, res.Duration))
+			}
+			switch {
+			case res.RejectFile != "":
+				fmt.Println(i18n.T("download.rejected", res.RowsRejected, res.RejectFile))
+			case res.RowsRejected > 0:
+				fmt.Println(i18n.T("download.rejected_count", res.RowsRejected))
+			}
+			return nil
 		})
 	},
 }
 
 func init() {
-	downloadCmd.Flags().StringVar(&downloadFields, "fields", "", "Comma-separated list of fields to export (optional)")
-	downloadCmd.Flags().StringVar(&downloadFormat, "format", "json", "Export format: json, tsv, csv, sqlite3, duckdb")
+	downloadCmd.Flags().StringVar(&downloadFields, "fields", "", "File with the fields to export, one per line (optional)")
+	downloadCmd.Flags().StringVar(&downloadFormat, "format", "json", "Export format: "+strings.Join(dbexport.Formats(), ", "))
 	downloadCmd.Flags().StringVar(&downloadDatabase, "database", "", "MSSQL database name (env: MSSQL_DATABASE)")
+	downloadCmd.Flags().StringVar(&downloadOutput, "output", "", "Output file (default: <table>.<format>, or output.sqlite3/output.duckdb)")
+	downloadCmd.Flags().StringVar(&downloadWhere, "where", "", "SQL filter applied to the exported rows, without the WHERE keyword")
+	downloadCmd.Flags().IntVar(&downloadBatchSize, "batch-size", 10000, "Rows written per batch (and per transaction for sqlite3/duckdb, and sql with --sql-transactions)")
+	downloadCmd.Flags().StringVar(&downloadOverwrite, "overwrite", "", "When the output exists: prompt, always or never (default: prompt for database tables, replace files)")
+	downloadCmd.Flags().StringVar(&downloadProgress, "progress", "auto", "Progress display: auto, bar, plain or none (auto uses bar on a terminal, plain otherwise)")
+	downloadCmd.Flags().StringVar(&downloadRowErrors, "on-row-error", "fail", "What to do with rows that cannot be read: fail, skip or log (skip and report on stderr)")
+	downloadCmd.Flags().StringVar(&downloadRejects, "reject-file", "", "File receiving the number and error of every failed row as JSON lines (default: <table>.rejects.jsonl with skip/log)")
+	downloadCmd.Flags().StringVar(&downloadTypes, "types", "native", "Column types for sqlite3/duckdb/parquet/arrow/avro/xlsx/sql/pgcopy: native (mapped from the MSSQL types) or text (every column TEXT)")
+	downloadCmd.Flags().StringVar(&downloadDatetime, "datetime-format", "iso", "How dates and times are written as text: iso (full precision), date (date only) or a Go layout such as \"2006-01-02 15:04:05\"")
+	downloadCmd.Flags().StringVar(&downloadTimezone, "timezone", "", "Convert datetimeoffset values to this zone before writing them as text, e.g. UTC, Local or Europe/Madrid")
+	downloadCmd.Flags().StringVar(&downloadTypesFile, "types-file", "", "YAML or JSON file overriding the exported type of columns (string, int, decimal, date, datetime, boolean)")
+	downloadCmd.Flags().StringVar(&downloadBinary, "binary-encoding", "auto", "How binary columns are written as text: auto (base64 in JSON, hex otherwise), base64 or hex")
+	downloadCmd.Flags().StringVar(&downloadCSVDialect, "csv-dialect", "default", "CSV dialect: default (comma, quoted when needed), rfc4180 (as default with CRLF line endings) or legacy (\"||\" separated, never quoted)")
+	downloadCmd.Flags().StringVar(&downloadCSVDelimiter, "csv-delimiter", "", "CSV field delimiter, overriding the dialect's (default: ,)")
+	downloadCmd.Flags().StringVar(&downloadCSVQuote, "csv-quote", "", "CSV quote character, overriding the dialect's (default: \")")
+	downloadCmd.Flags().BoolVar(&downloadCSVQuoteAll, "csv-quote-all", false, "Quote every CSV field, not only those that need it")
+	downloadCmd.Flags().StringVar(&downloadCSVLineEnd, "csv-line-terminator", "", "CSV line endings, overriding the dialect's: lf or crlf")
+	downloadCmd.Flags().BoolVar(&downloadCSVHeader, "csv-header", true, "Write the column names as the first CSV line")
+	downloadCmd.Flags().StringVar(&downloadCSVNull, "csv-null", "", "Text written for NULL values in CSV (default: empty field)")
+	downloadCmd.Flags().StringVar(&downloadTSVEscaping, "tsv-escaping", "text", "TSV convention: text (PostgreSQL: \\t, \\n, \\r and \\\\ escaped, \\N for NULL) or iana (values as they are, rows with tabs or line breaks fail)")
+	downloadCmd.Flags().BoolVar(&downloadGzip, "gzip", false, "Compress file formats with gzip (adds .gz to the default file name)")
+	downloadCmd.Flags().Int64Var(&downloadParquetRowGroup, "parquet-row-group-size", dbexport.DefaultParquetRowGroupSize, "Rows per Parquet row group (each row group is held in memory until written)")
+	downloadCmd.Flags().StringVar(&downloadParquetCompression, "parquet-compression", "snappy", "Parquet compression codec: snappy, zstd, gzip or none")
+	downloadCmd.Flags().BoolVar(&downloadArrowStream, "arrow-stream", false, "Write the Arrow IPC streaming format (<table>.arrows) instead of an Arrow file (<table>.arrow)")
+	downloadCmd.Flags().StringVar(&downloadAvroCodec, "avro-codec", "deflate", "Avro block compression codec: deflate, snappy, zstd or none")
+	downloadCmd.Flags().StringVar(&downloadSQLDialect, "dialect", "mssql", "Target database of --format=sql: mssql, postgres, mysql or sqlite")
+	downloadCmd.Flags().IntVar(&downloadSQLInsertRows, "sql-insert-rows", dbexport.DefaultSQLInsertRows, "Rows per INSERT statement of --format=sql (at most 1000 for mssql)")
+	downloadCmd.Flags().BoolVar(&downloadSQLTransactions, "sql-transactions", false, "Wrap the INSERT statements of each batch in a transaction with --format=sql")
 	rootCmd.AddCommand(downloadCmd)
 }
+
+// csvDialectFromFlags returns the dialect selected by --csv-dialect with the
+// other --csv-* flags given on the command line applied over it.
+func csvDialectFromFlags(cmd *cobra.Command) (dbexport.CSVDialect, error) {
+	d, err := dbexport.CSVDialectByName(downloadCSVDialect)
+	if err != nil {
+		return d, err
+	}
+	flags := cmd.Flags()
+	if flags.Changed("csv-delimiter") {
+		d.Delimiter = downloadCSVDelimiter
+	}
+	if flags.Changed("csv-quote") {
+		d.Quote = downloadCSVQuote
+		d.NoQuoting = false
+	}
+	if flags.Changed("csv-quote-all") {
+		d.QuoteAll = downloadCSVQuoteAll
+		if d.QuoteAll {
+			d.NoQuoting = false
+		}
+	}
+	if flags.Changed("csv-line-terminator") {
+		switch strings.ToLower(downloadCSVLineEnd) {
+		case "lf":
+			d.LineTerminator = "\n"
+		case "crlf":
+			d.LineTerminator = "\r\n"
+		default:
+			return d, i18n.Errorf("csv.bad_line_terminator", downloadCSVLineEnd)
+		}
+	}
+	if flags.Changed("csv-header") {
+		d.NoHeader = !downloadCSVHeader
+	}
+	if flags.Changed("csv-null") {
+		d.Null = downloadCSVNull
+	}
+	return d, d.Validate()
+}
+
+// newProgressReporter returns the progress reporter selected by the --progress flag.
+func newProgressReporter(mode string) (dbexport.ProgressReporter, error) {
+	if mode == "auto" {
+		mode = "plain"
+		if isTerminal(os.Stdout) {
+			mode = "bar"
+		}
+	}
+	switch mode {
+	case "bar":
+		return dbexport.NewTerminalProgress(os.Stdout), nil
+	case "plain":
+		return dbexport.NewLineProgress(os.Stdout, 5*time.Second), nil
+	case "none":
+		return dbexport.NoProgress, nil
+	}
+	return nil, i18n.Errorf("cli.invalid_progress", mode)
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
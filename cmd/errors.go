@@ -1,50 +1,60 @@
 package cmd
 
 import (
-	"log"
-	"strings"
+	"errors"
+	"getmssql/dbexport"
+	"getmssql/i18n"
 )
 
-// isInvalidTableError checks recursively for substrings indicating a missing/invalid table in any wrapped error.
-func isInvalidTableError(err error) bool {
-	log.Printf("[DEBUG] isInvalidTableError called with: %v\n", err)
-	var patterns = []string{
-		"could not get total row count",
-		"is not a valid object name",
-		"invalid object name",
-		"el nombre de objeto",
-		"no es válido",
-		"does not exist",
-		"no existe",
-		"object does not exist",
-		"table does not exist",
-		"invalid table name",
-		"could not find object",
-		"no such table",
-	}
-	matched := false
-	origErr := err
-	for err != nil {
-		errStr := strings.ToLower(err.Error())
-		for _, pat := range patterns {
-			if strings.Contains(errStr, pat) {
-				matched = true
-				return true
-			}
-		}
-		if strings.Contains(errStr, "no es válido") && strings.Contains(errStr, "nombre de objeto") {
-			matched = true
-			return true
-		}
-		type unwrapper interface{ Unwrap() error }
-		if u, ok := err.(unwrapper); ok {
-			err = u.Unwrap()
-		} else {
-			break
+// errAborted is returned when the user interrupts a command with Ctrl-C.
+var errAborted error = i18n.NewError("cli.interrupted")
+
+// errorClass ties an error class to the CLI exit code and the catalog key of
+// the hint shown for it.
+type errorClass struct {
+	err  error
+	code int
+	hint string
+}
+
+// errorClasses lists the error classes the CLI reports specially.
+// Any other error exits with code 1 and no hint.
+var errorClasses = []errorClass{
+	{dbexport.ErrTableNotFound, 3, "hint.table_not_found"},
+	{dbexport.ErrColumnNotFound, 4, "hint.column_not_found"},
+	{dbexport.ErrPermissionDenied, 5, "hint.permission_denied"},
+	{dbexport.ErrLoginFailed, 6, "hint.login_failed"},
+	{dbexport.ErrDatabaseNotFound, 7, "hint.database_not_found"},
+	{errAborted, 130, ""},
+}
+
+// exitCodeFor returns the process exit code for err.
+func exitCodeFor(err error) int {
+	for _, c := range errorClasses {
+		if errors.Is(err, c.err) {
+			return c.code
 		}
 	}
-	if !matched && origErr != nil {
-		log.Printf("[DEBUG] isInvalidTableError: no match for error: %v\n", origErr)
+	return 1
+}
+
+// hintedError adds a hint for the user to an error message.
+type hintedError struct {
+	err  error
+	hint string
+}
+
+func (h *hintedError) Error() string { return h.err.Error() + ".\n\n" + h.hint }
+
+func (h *hintedError) Unwrap() error { return h.err }
+
+// withHint appends the hint for err's error class to its message.
+// The original error stays in the chain for errors.Is and exitCodeFor.
+func withHint(err error) error {
+	for _, c := range errorClasses {
+		if c.hint != "" && errors.Is(err, c.err) {
+			return &hintedError{err: err, hint: i18n.T(c.hint)}
+		}
 	}
-	return false
+	return err
 }
@@ -3,86 +3,87 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"getmssql/dbexport"
+	"getmssql/i18n"
+	"strings"
 	"testing"
 )
 
-type wrapErr struct{ inner error }
+// numberedErr mimics the driver's mssql.Error
+type numberedErr struct{ number int32 }
 
-func (w wrapErr) Error() string { return "wrap: " + w.inner.Error() }
-func (w wrapErr) Unwrap() error { return w.inner }
+func (n numberedErr) Error() string         { return fmt.Sprintf("mssql: error %d", n.number) }
+func (n numberedErr) SQLErrorNumber() int32 { return n.number }
 
-func TestIsInvalidTableError_CombinedSpanishOnly(t *testing.T) {
-	err := fmt.Errorf("nombre de objeto foo no es válido")
-	if !isInvalidTableError(err) {
-		t.Error("expected true for combined Spanish substring match")
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{errors.New("something else"), 1},
+		{dbexport.ClassifyError(numberedErr{208}), 3},
+		{dbexport.ClassifyError(numberedErr{207}), 4},
+		{dbexport.ClassifyError(numberedErr{229}), 5},
+		{fmt.Errorf("cannot connect to database: %w", dbexport.ClassifyError(numberedErr{18456})), 6},
+		{dbexport.ClassifyError(numberedErr{4060}), 7},
+		{errAborted, 130},
+		{withHint(dbexport.ClassifyError(numberedErr{208})), 3},
 	}
-}
-
-func TestIsInvalidTableError_UnwrapNoMatch(t *testing.T) {
-	err := wrapErr{customErr{}}
-	if isInvalidTableError(err) {
-		t.Error("expected false for wrapped custom error")
+	for _, c := range cases {
+		if got := exitCodeFor(c.err); got != c.code {
+			t.Errorf("exitCodeFor(%v) = %d, want %d", c.err, got, c.code)
+		}
 	}
 }
 
-func TestIsInvalidTableError_Nil(t *testing.T) {
-	if isInvalidTableError(nil) {
-		t.Error("expected false for nil error")
+func TestWithHint(t *testing.T) {
+	err := withHint(fmt.Errorf("could not get total row count: %w", dbexport.ClassifyError(numberedErr{208})))
+	if !strings.Contains(err.Error(), "could not get total row count: mssql: error 208.\n\n") || !strings.Contains(err.Error(), "schema.table") {
+		t.Errorf("expected table hint, got: %q", err.Error())
 	}
-}
-
-type customErr struct{}
-
-func (c customErr) Error() string { return "definitely not a table error" }
-
-func TestIsInvalidTableError_NoMatch_TriggersLog(t *testing.T) {
-	err := customErr{}
-	if isInvalidTableError(err) {
-		t.Error("expected false for custom error")
+	if !errors.Is(err, dbexport.ErrTableNotFound) {
+		t.Error("expected hinted error to keep its class")
 	}
-}
 
-func TestIsInvalidTableError_MatchesPatterns(t *testing.T) {
-	patterns := []string{
-		"could not get total row count",
-		"is not a valid object name",
-		"invalid object name",
-		"el nombre de objeto",
-		"no es válido",
-		"does not exist",
-		"no existe",
-		"object does not exist",
-		"table does not exist",
-		"invalid table name",
-		"could not find object",
-		"no such table",
+	defer i18n.SetLanguage(i18n.English)
+	if err := setLanguage("es"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	for _, pat := range patterns {
-		err := fmt.Errorf("some error: %s", pat)
-		if !isInvalidTableError(err) {
-			t.Errorf("expected true for pattern: %q", pat)
-		}
+	err = withHint(dbexport.ClassifyError(numberedErr{208}))
+	if !strings.Contains(err.Error(), "esquema.tabla") {
+		t.Errorf("expected Spanish table hint, got: %q", err.Error())
 	}
-}
+	i18n.SetLanguage(i18n.English)
 
-func TestIsInvalidTableError_CombinedSpanish(t *testing.T) {
-	err := fmt.Errorf("el nombre de objeto foo no es válido")
-	if !isInvalidTableError(err) {
-		t.Error("expected true for combined Spanish error")
+	err = withHint(dbexport.ClassifyError(numberedErr{18456}))
+	if !strings.Contains(err.Error(), "MSSQL_PASSWORD") {
+		t.Errorf("expected login hint, got: %q", err.Error())
 	}
-}
 
-func TestIsInvalidTableError_Unwrap(t *testing.T) {
-	base := errors.New("invalid object name")
-	wrapped := fmt.Errorf("wrap1: %w", base)
-	if !isInvalidTableError(wrapped) {
-		t.Error("expected true for wrapped error")
+	plain := errors.New("plain")
+	if withHint(plain) != plain {
+		t.Error("expected unclassified error to be returned unchanged")
+	}
+	if withHint(nil) != nil {
+		t.Error("expected nil for nil")
+	}
+	if withHint(errAborted) != errAborted {
+		t.Error("expected no hint for Ctrl-C")
 	}
 }
 
-func TestIsInvalidTableError_NoMatch(t *testing.T) {
-	err := errors.New("some unrelated error")
-	if isInvalidTableError(err) {
-		t.Error("expected false for unrelated error")
+func TestSetLanguage(t *testing.T) {
+	defer i18n.SetLanguage(i18n.English)
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	if err := setLanguage(""); err != nil || i18n.Language() != "es" {
+		t.Errorf("expected es from LANG, got %q (err: %v)", i18n.Language(), err)
+	}
+	if err := setLanguage("en"); err != nil || i18n.Language() != "en" {
+		t.Errorf("expected --lang to win over LANG, got %q (err: %v)", i18n.Language(), err)
+	}
+	if err := setLanguage("xx"); err == nil {
+		t.Error("expected error for unsupported --lang")
 	}
 }
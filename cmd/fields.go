@@ -3,7 +3,6 @@ package cmd
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"getmssql/dbexport"
 
 	"github.com/spf13/cobra"
@@ -16,13 +15,7 @@ var fieldsCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		table := args[0]
 		return withDB("", func(ctx context.Context, db *sql.DB) error {
-			err := dbexport.ListFields(db, table)
-			if err != nil {
-				if isInvalidTableError(err) {
-					return fmt.Errorf("%v.\n\nverifica que el nombre de la tabla o vista exista en la base de datos y esté correctamente escrito. si pertenece a otro esquema, usa el nombre completo (por ejemplo: esquema.tabla)", err)
-				}
-			}
-			return err
+			return dbexport.ListFields(db, table)
 		})
 	},
 }
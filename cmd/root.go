@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"getmssql/i18n"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -13,12 +15,16 @@ var (
 	FlagUser     string
 	FlagPassword string
 	FlagDatabase string
+	FlagLang     string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "getmssql",
 	Short: "Export MSSQL data to various formats",
 	Long:  `A CLI tool to export MSSQL data to JSON, CSV, TSV, SQLite, or DuckDB formats.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setLanguage(FlagLang)
+	},
 }
 
 var exitFunc = os.Exit
@@ -26,7 +32,7 @@ var exitFunc = os.Exit
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		exitFunc(1)
+		exitFunc(exitCodeFor(err))
 	}
 }
 
@@ -36,4 +42,14 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&FlagUser, "user", "", "MSSQL username (env: MSSQL_USER)")
 	rootCmd.PersistentFlags().StringVar(&FlagPassword, "password", "", "MSSQL password (env: MSSQL_PASSWORD)")
 	rootCmd.PersistentFlags().StringVar(&FlagDatabase, "database", "", "MSSQL database name (env: MSSQL_DATABASE)")
+	rootCmd.PersistentFlags().StringVar(&FlagLang, "lang", "", "Message language: "+strings.Join(i18n.Languages(), ", ")+" (default: from LC_ALL, LC_MESSAGES or LANG)")
+}
+
+// setLanguage selects the message language from the --lang flag, falling back
+// to the locale environment variables.
+func setLanguage(lang string) error {
+	if lang == "" {
+		lang = i18n.Detect(os.Getenv)
+	}
+	return i18n.SetLanguage(lang)
 }
@@ -0,0 +1,250 @@
+package dbexport
+
+import (
+	"encoding/json"
+	"getmssql/i18n"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// arrowType maps an MSSQL column to the Arrow type holding its values. Dates
+// and times are kept to the microsecond, which covers the full range of
+// datetime2; its seventh fractional digit is dropped. Types Arrow has no
+// equivalent for, and every column with TypesText, are strings.
+func arrowType(col Column, mode TypeMode) arrow.DataType {
+	if mode == TypesText {
+		return arrow.BinaryTypes.String
+	}
+	switch strings.ToUpper(col.DatabaseType) {
+	case "BIT":
+		return arrow.FixedWidthTypes.Boolean
+	case "TINYINT":
+		return arrow.PrimitiveTypes.Uint8
+	case "SMALLINT":
+		return arrow.PrimitiveTypes.Int16
+	case "INT":
+		return arrow.PrimitiveTypes.Int32
+	case "BIGINT":
+		return arrow.PrimitiveTypes.Int64
+	case "REAL":
+		return arrow.PrimitiveTypes.Float32
+	case "FLOAT":
+		return arrow.PrimitiveTypes.Float64
+	case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY":
+		if p := decimalPrecision(col); p > 0 && p <= 38 {
+			return &arrow.Decimal128Type{Precision: int32(p), Scale: int32(decimalScale(col))}
+		}
+	case "DATE":
+		return arrow.FixedWidthTypes.Date32
+	case "TIME":
+		return arrow.FixedWidthTypes.Time64ns
+	case "DATETIME2", "DATETIME", "SMALLDATETIME":
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case "DATETIMEOFFSET":
+		// An instant, normalized to UTC
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case "BINARY", "VARBINARY", "IMAGE", "TIMESTAMP", "ROWVERSION", "GEOGRAPHY", "GEOMETRY":
+		return arrow.BinaryTypes.Binary
+	}
+	return arrow.BinaryTypes.String
+}
+
+// arrowSchema returns the Arrow schema of cols, see arrowType.
+func arrowSchema(cols []Column, mode TypeMode) *arrow.Schema {
+	fields := make([]arrow.Field, len(cols))
+	for i, col := range cols {
+		fields[i] = arrow.Field{Name: col.Name, Type: arrowType(col, mode), Nullable: col.Nullable}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// arrowEncoder turns rows of driver values into Arrow records. It serves the
+// writers built on Arrow: prepareRow converts each value to the Go type of
+// its column's builder, so that values that do not fit fail their row, and
+// record then builds a record from the prepared rows.
+type arrowEncoder struct {
+	cols   []Column
+	schema *arrow.Schema
+	conv   valueConverter // text form of string columns
+	rb     *array.RecordBuilder
+}
+
+func newArrowEncoder(cols []Column, opts WriterOptions) *arrowEncoder {
+	schema := arrowSchema(cols, opts.Types)
+	return &arrowEncoder{
+		cols:   cols,
+		schema: schema,
+		conv:   newTextConverter(opts),
+		rb:     array.NewRecordBuilder(memory.DefaultAllocator, schema),
+	}
+}
+
+// prepareRow converts a row of driver values in place, see arrowValue.
+func (e *arrowEncoder) prepareRow(row []interface{}) error {
+	for i, v := range row {
+		val, err := e.arrowValue(i, v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", e.cols[i].Name, err)
+		}
+		row[i] = val
+	}
+	return nil
+}
+
+// arrowValue converts the driver value v of column i to the value its
+// builder appends: bool, the sized integer or float, decimal128.Num,
+// arrow.Date32, arrow.Time64, arrow.Timestamp, []byte or string.
+func (e *arrowEncoder) arrowValue(i int, v interface{}) (interface{}, error) {
+	if v == nil {
+		if !e.cols[i].Nullable {
+			return nil, i18n.Errorf("arrow.null_value")
+		}
+		return nil, nil
+	}
+	typ := e.schema.Field(i).Type
+	switch typ.ID() {
+	case arrow.STRING:
+		s, err := e.conv.convert(e.cols[i], v)
+		if err != nil {
+			return nil, err
+		}
+		return formatDelimitedValues([]interface{}{s}, "")[0], nil
+	case arrow.BOOL:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case arrow.UINT8, arrow.INT16, arrow.INT32, arrow.INT64:
+		if n, ok := v.(int64); ok {
+			return arrowInt(typ.ID(), n)
+		}
+	case arrow.FLOAT32, arrow.FLOAT64:
+		var f float64
+		switch x := v.(type) {
+		case float64:
+			f = x
+		case float32:
+			f = float64(x)
+		default:
+			return nil, i18n.Errorf("arrow.bad_value", v, typ)
+		}
+		if typ.ID() == arrow.FLOAT32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case arrow.DECIMAL128:
+		var text string
+		switch x := v.(type) {
+		case []byte:
+			text = string(x)
+		case string:
+			text = x
+		case json.Number:
+			text = string(x)
+		default:
+			return nil, i18n.Errorf("arrow.bad_value", v, typ)
+		}
+		dt := typ.(*arrow.Decimal128Type)
+		n, err := decimal128.FromString(text, dt.Precision, dt.Scale)
+		if err != nil || !n.FitsInPrecision(dt.Precision) {
+			return nil, i18n.Errorf("arrow.bad_value", text, typ)
+		}
+		return n, nil
+	case arrow.DATE32:
+		if t, ok := v.(time.Time); ok {
+			midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return arrow.Date32(midnight.Unix() / 86400), nil
+		}
+	case arrow.TIME64:
+		if t, ok := v.(time.Time); ok {
+			since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+			return arrow.Time64(since), nil
+		}
+	case arrow.TIMESTAMP:
+		if t, ok := v.(time.Time); ok {
+			if typ.(*arrow.TimestampType).TimeZone == "" {
+				// Keep the wall clock of types without an offset
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			}
+			return arrow.Timestamp(t.UnixMicro()), nil
+		}
+	case arrow.BINARY:
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+	}
+	return nil, i18n.Errorf("arrow.bad_value", v, typ)
+}
+
+// arrowInt narrows n to the integer type id, failing when it does not fit.
+func arrowInt(id arrow.Type, n int64) (interface{}, error) {
+	switch {
+	case id == arrow.UINT8 && n >= 0 && n <= math.MaxUint8:
+		return uint8(n), nil
+	case id == arrow.INT16 && n >= math.MinInt16 && n <= math.MaxInt16:
+		return int16(n), nil
+	case id == arrow.INT32 && n >= math.MinInt32 && n <= math.MaxInt32:
+		return int32(n), nil
+	case id == arrow.INT64:
+		return n, nil
+	}
+	return nil, i18n.Errorf("arrow.bad_value", n, id)
+}
+
+// record builds a record from rows prepared by prepareRow. The caller
+// releases it.
+func (e *arrowEncoder) record(rows [][]interface{}) arrow.Record {
+	for _, row := range rows {
+		for i, v := range row {
+			appendArrow(e.rb.Field(i), v)
+		}
+	}
+	return e.rb.NewRecord()
+}
+
+// release frees the record builder.
+func (e *arrowEncoder) release() {
+	e.rb.Release()
+}
+
+// appendArrow appends a value converted by arrowValue to b.
+func appendArrow(b array.Builder, v interface{}) {
+	if v == nil {
+		b.AppendNull()
+		return
+	}
+	switch b := b.(type) {
+	case *array.StringBuilder:
+		b.Append(v.(string))
+	case *array.BinaryBuilder:
+		b.Append(v.([]byte))
+	case *array.BooleanBuilder:
+		b.Append(v.(bool))
+	case *array.Uint8Builder:
+		b.Append(v.(uint8))
+	case *array.Int16Builder:
+		b.Append(v.(int16))
+	case *array.Int32Builder:
+		b.Append(v.(int32))
+	case *array.Int64Builder:
+		b.Append(v.(int64))
+	case *array.Float32Builder:
+		b.Append(v.(float32))
+	case *array.Float64Builder:
+		b.Append(v.(float64))
+	case *array.Decimal128Builder:
+		b.Append(v.(decimal128.Num))
+	case *array.Date32Builder:
+		b.Append(v.(arrow.Date32))
+	case *array.Time64Builder:
+		b.Append(v.(arrow.Time64))
+	case *array.TimestampBuilder:
+		b.Append(v.(arrow.Timestamp))
+	}
+}
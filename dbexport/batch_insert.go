@@ -0,0 +1,76 @@
+package dbexport
+
+import (
+	"context"
+	"database/sql"
+	"getmssql/i18n"
+)
+
+// batchInserter loads rows into a database/sql target through a prepared
+// INSERT statement, committing one transaction per batch.
+// It is shared by the SQLite3 and DuckDB writers.
+type batchInserter struct {
+	db     *sql.DB
+	engine string // used in error messages, e.g. "SQLite3"
+	query  string
+	tx     *sql.Tx
+	stmt   *sql.Stmt
+}
+
+// begin starts a transaction and prepares the insert statement in it.
+// The transaction is rolled back if ctx is cancelled before it commits.
+func (b *batchInserter) begin(ctx context.Context) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return i18n.Errorf("db.begin_error", b.engine, err)
+	}
+	stmt, err := tx.PrepareContext(ctx, b.query)
+	if err != nil {
+		tx.Rollback()
+		return i18n.Errorf("db.prepare_error", b.engine, err)
+	}
+	b.tx, b.stmt = tx, stmt
+	return nil
+}
+
+// insert executes the statement for every row and commits the batch.
+// A transaction is started first if none is open.
+func (b *batchInserter) insert(ctx context.Context, rows [][]interface{}) error {
+	if b.tx == nil {
+		if err := b.begin(ctx); err != nil {
+			return err
+		}
+	}
+	for _, vals := range rows {
+		if _, err := b.stmt.ExecContext(ctx, vals...); err != nil {
+			b.rollback()
+			return i18n.Errorf("db.insert_error", b.engine, err)
+		}
+	}
+	return b.commit()
+}
+
+func (b *batchInserter) commit() error {
+	tx := b.tx
+	b.stmt.Close()
+	b.tx, b.stmt = nil, nil
+	if err := tx.Commit(); err != nil {
+		return i18n.Errorf("db.commit_error", b.engine, err)
+	}
+	return nil
+}
+
+func (b *batchInserter) rollback() {
+	b.stmt.Close()
+	b.tx.Rollback()
+	b.tx, b.stmt = nil, nil
+}
+
+// close commits the open transaction, if any, and closes the database.
+func (b *batchInserter) close() error {
+	defer b.db.Close()
+	if b.tx != nil {
+		return b.commit()
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package dbexport
+
+import (
+	"encoding/csv"
+	"getmssql/i18n"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVDialect describes how the csv format writes records. The zero value is
+// the default dialect: comma separated, fields quoted with " only when
+// needed, LF line endings, a header line and NULL written as an empty field.
+type CSVDialect struct {
+	// Delimiter separates fields; "" means ",". Only dialects without
+	// quoting may use a delimiter longer than one character.
+	Delimiter string
+	// Quote encloses fields; "" means `"`. Quotes inside a field are doubled.
+	Quote string
+	// QuoteAll quotes every field, not only those that need it.
+	QuoteAll bool
+	// NoQuoting writes fields as they are, as the legacy dialect does.
+	NoQuoting bool
+	// LineTerminator ends each record: "\n" (the default) or "\r\n".
+	LineTerminator string
+	// NoHeader leaves out the line with the column names.
+	NoHeader bool
+	// Null is written for NULL values.
+	Null string
+}
+
+// CSVDialects are the named dialects accepted by CSVDialectByName.
+var CSVDialects = map[string]CSVDialect{
+	"default": {},
+	"rfc4180": {LineTerminator: "\r\n"},
+	// legacy is the "||" separated format of older versions, without quoting
+	"legacy": {Delimiter: "||", NoQuoting: true},
+}
+
+// CSVDialectByName returns the named dialect; "" selects "default".
+func CSVDialectByName(name string) (CSVDialect, error) {
+	if name == "" {
+		name = "default"
+	}
+	d, ok := CSVDialects[name]
+	if !ok {
+		names := make([]string, 0, len(CSVDialects))
+		for n := range CSVDialects {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return CSVDialect{}, i18n.Errorf("csv.unknown_dialect", name, strings.Join(names, ", "))
+	}
+	return d, nil
+}
+
+func (d CSVDialect) delimiter() string {
+	if d.Delimiter == "" {
+		return ","
+	}
+	return d.Delimiter
+}
+
+func (d CSVDialect) quote() string {
+	if d.Quote == "" {
+		return `"`
+	}
+	return d.Quote
+}
+
+func (d CSVDialect) lineTerminator() string {
+	if d.LineTerminator == "" {
+		return "\n"
+	}
+	return d.LineTerminator
+}
+
+// Validate reports a dialect whose files could not be read back.
+func (d CSVDialect) Validate() error {
+	delim, quote := d.delimiter(), d.quote()
+	switch {
+	case strings.ContainsAny(delim, "\r\n"):
+		return i18n.Errorf("csv.bad_delimiter", delim)
+	case d.NoQuoting && d.QuoteAll:
+		return i18n.Errorf("csv.quote_conflict")
+	case d.NoQuoting:
+	case utf8.RuneCountInString(delim) != 1:
+		return i18n.Errorf("csv.bad_delimiter", delim)
+	case utf8.RuneCountInString(quote) != 1 || strings.ContainsAny(quote, "\r\n") || quote == delim:
+		return i18n.Errorf("csv.bad_quote", quote)
+	}
+	if t := d.lineTerminator(); t != "\n" && t != "\r\n" {
+		return i18n.Errorf("csv.bad_line_terminator", t)
+	}
+	return nil
+}
+
+// csvEncoder writes records in a CSVDialect. Dialects that encoding/csv
+// supports are written with it; quote-all, other quote characters and the
+// legacy dialect follow the same quoting rules in write.
+type csvEncoder struct {
+	dialect CSVDialect
+	out     io.Writer
+	std     *csv.Writer
+}
+
+func newCSVEncoder(out io.Writer, d CSVDialect) *csvEncoder {
+	e := &csvEncoder{dialect: d, out: out}
+	if !d.NoQuoting && !d.QuoteAll && d.quote() == `"` {
+		e.std = csv.NewWriter(out)
+		e.std.Comma, _ = utf8.DecodeRuneInString(d.delimiter())
+		e.std.UseCRLF = d.lineTerminator() == "\r\n"
+	}
+	return e
+}
+
+// writeValues writes a row of converted values as one record. NULL values
+// are written as the Null token, which is not quoted even with QuoteAll, so
+// that readers can tell it from text.
+func (e *csvEncoder) writeValues(vals []interface{}) error {
+	fields := formatDelimitedValues(vals, e.dialect.Null)
+	if e.std != nil {
+		return e.std.Write(fields)
+	}
+	return e.write(fields, func(i int) bool { return vals[i] == nil })
+}
+
+// writeHeader writes the column names as one record.
+func (e *csvEncoder) writeHeader(names []string) error {
+	if e.std != nil {
+		return e.std.Write(names)
+	}
+	return e.write(names, func(int) bool { return false })
+}
+
+// write writes one record without encoding/csv.
+func (e *csvEncoder) write(fields []string, isNull func(int) bool) error {
+	var b strings.Builder
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteString(e.dialect.delimiter())
+		}
+		if !isNull(i) && e.needsQuotes(field) {
+			q := e.dialect.quote()
+			b.WriteString(q + strings.ReplaceAll(field, q, q+q) + q)
+		} else {
+			b.WriteString(field)
+		}
+	}
+	b.WriteString(e.dialect.lineTerminator())
+	_, err := io.WriteString(e.out, b.String())
+	return err
+}
+
+// needsQuotes follows encoding/csv: fields with the delimiter, the quote, a
+// line break or a leading space are quoted, as is a lone `\.`.
+func (e *csvEncoder) needsQuotes(field string) bool {
+	switch {
+	case e.dialect.NoQuoting:
+		return false
+	case e.dialect.QuoteAll:
+		return true
+	case field == "":
+		return false
+	case field == `\.`:
+		return true
+	}
+	return strings.Contains(field, e.dialect.delimiter()) || strings.Contains(field, e.dialect.quote()) ||
+		strings.ContainsAny(field, "\r\n") || field[0] == ' ' || field[0] == '\t'
+}
+
+// flush writes any buffered records.
+func (e *csvEncoder) flush() error {
+	if e.std == nil {
+		return nil
+	}
+	e.std.Flush()
+	return e.std.Error()
+}
@@ -0,0 +1,115 @@
+package dbexport
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeCSV exports data in dialect d and returns the file contents.
+func writeCSV(t *testing.T, d CSVDialect, cols []Column, data [][]interface{}) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out.csv")
+	w := newFileWriter(WriterOptions{Table: "t", Output: out, CSV: d}, "csv")
+	rows := &staticRows{cols: columnNames(cols), data: data}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
+func TestCSV_RoundTrip(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "INT"}, {Name: "note", DatabaseType: "NVARCHAR"}}
+	notes := []string{"plain", "a,b", `say "hi"`, "two\nlines", "cr\r\nlf", " leading space", `\.`, "||"}
+	var data [][]interface{}
+	want := [][]string{{"id", "note"}}
+	for i, note := range notes {
+		data = append(data, []interface{}{int64(i), note})
+		want = append(want, []string{string(rune('0' + i)), note})
+	}
+	for name, d := range map[string]CSVDialect{
+		"default":   {},
+		"rfc4180":   CSVDialects["rfc4180"],
+		"semicolon": {Delimiter: ";"},
+		"quote all": {QuoteAll: true},
+	} {
+		r := csv.NewReader(strings.NewReader(writeCSV(t, d, cols, data)))
+		r.Comma = []rune(d.delimiter())[0]
+		got, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("%s: reading back: %v", name, err)
+		}
+		// encoding/csv reads \r\n inside quoted fields as \n
+		want[5][1] = "cr\nlf"
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read back %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCSV_Dialects(t *testing.T) {
+	cols := []Column{{Name: "a", DatabaseType: "NVARCHAR"}, {Name: "b", DatabaseType: "NVARCHAR"}}
+	data := [][]interface{}{{"x,y", nil}, {`it's`, ""}}
+	cases := []struct {
+		name string
+		d    CSVDialect
+		want string
+	}{
+		{"default", CSVDialect{}, "a,b\n\"x,y\",\nit's,\n"},
+		{"rfc4180", CSVDialects["rfc4180"], "a,b\r\n\"x,y\",\r\nit's,\r\n"},
+		{"legacy", CSVDialects["legacy"], "a||b\nx,y||\nit's||\n"},
+		{"quote all", CSVDialect{QuoteAll: true, Null: "NULL"}, "\"a\",\"b\"\n\"x,y\",NULL\n\"it's\",\"\"\n"},
+		{"single quote", CSVDialect{Quote: "'"}, "a,b\n'x,y',\n'it''s',\n"},
+		{"no header", CSVDialect{NoHeader: true, Null: `\N`}, "\"x,y\",\\N\nit's,\n"},
+		{"tab crlf", CSVDialect{Delimiter: "\t", LineTerminator: "\r\n"}, "a\tb\r\nx,y\t\r\nit's\t\r\n"},
+	}
+	for _, c := range cases {
+		if got := writeCSV(t, c.d, cols, data); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCSVDialect_Validate(t *testing.T) {
+	for _, d := range []CSVDialect{{}, CSVDialects["rfc4180"], CSVDialects["legacy"], {Delimiter: "|", Quote: "'", QuoteAll: true}} {
+		if err := d.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", d, err)
+		}
+	}
+	for _, d := range []CSVDialect{
+		{Delimiter: "||"},
+		{Delimiter: "\n"},
+		{Quote: `""`},
+		{Delimiter: "'", Quote: "'"},
+		{NoQuoting: true, QuoteAll: true},
+		{LineTerminator: "\r"},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", d)
+		}
+	}
+	w := newFileWriter(WriterOptions{Output: filepath.Join(t.TempDir(), "out.csv"), CSV: CSVDialect{Delimiter: ";;"}}, "csv")
+	if err := w.Open(context.Background(), []Column{{Name: "a"}}); err == nil || !strings.Contains(err.Error(), "invalid CSV delimiter") {
+		t.Errorf("Open: got %v, want an invalid delimiter error", err)
+	}
+}
+
+func TestCSVDialectByName(t *testing.T) {
+	if d, err := CSVDialectByName(""); err != nil || d != (CSVDialect{}) {
+		t.Errorf("empty name: got %+v, %v", d, err)
+	}
+	if d, err := CSVDialectByName("legacy"); err != nil || d.Delimiter != "||" || !d.NoQuoting {
+		t.Errorf("legacy: got %+v, %v", d, err)
+	}
+	if _, err := CSVDialectByName("excel"); err == nil || !strings.Contains(err.Error(), "default, legacy, rfc4180") {
+		t.Errorf("unknown dialect: got %v", err)
+	}
+}
@@ -3,26 +3,27 @@ package dbexport
 import (
 	"database/sql"
 	"fmt"
+	"getmssql/i18n"
 )
 
 func ListTables(db *sql.DB) error {
 	query := `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME`
 	rows, err := db.Query(query)
 	if err != nil {
-		return fmt.Errorf("error querying tables: %w", err)
+		return i18n.Errorf("tables.query_error", ClassifyError(err))
 	}
 	defer rows.Close()
 
-	fmt.Println("Tables in the database:")
+	fmt.Println(i18n.T("tables.header"))
 	for rows.Next() {
 		var tableName string
 		if err := rows.Scan(&tableName); err != nil {
-			return fmt.Errorf("error scanning table name: %w", err)
+			return i18n.Errorf("tables.scan_error", err)
 		}
 		fmt.Println(tableName)
 	}
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("row error: %w", err)
+		return i18n.Errorf("rows.error", ClassifyError(err))
 	}
 	return nil
 }
@@ -31,21 +32,27 @@ func ListFields(db *sql.DB, table string) error {
 	query := `SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 ORDER BY ORDINAL_POSITION`
 	rows, err := db.Query(query, table)
 	if err != nil {
-		return fmt.Errorf("error querying fields: %w", err)
+		return i18n.Errorf("fields.query_error", ClassifyError(err))
 	}
 	defer rows.Close()
 
-	fmt.Printf("Fields in table '%s':\n", table)
-	fmt.Println("Column Name\tType\tNullable")
+	fmt.Println(i18n.T("fields.header", table))
+	fmt.Println(i18n.T("fields.columns"))
+	found := 0
 	for rows.Next() {
 		var colName, dataType, isNullable string
 		if err := rows.Scan(&colName, &dataType, &isNullable); err != nil {
-			return fmt.Errorf("error scanning field: %w", err)
+			return i18n.Errorf("fields.scan_error", err)
 		}
 		fmt.Printf("%s\t%s\t%s\n", colName, dataType, isNullable)
+		found++
 	}
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("row error: %w", err)
+		return i18n.Errorf("rows.error", ClassifyError(err))
+	}
+	// INFORMATION_SCHEMA returns no rows, rather than an error, for unknown tables
+	if found == 0 {
+		return i18n.Errorf("fields.none_found", table, ErrTableNotFound)
 	}
 	return nil
 }
@@ -2,6 +2,8 @@ package dbexport
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -149,6 +151,27 @@ func (s *stubRows) Scan(dest ...interface{}) error {
 func (s *stubRows) Columns() ([]string, error) { return []string{"a"}, nil }
 func (s *stubRows) Err() error                 { return nil }
 
+// staticRows serves fixed rows of values through the Rows interface
+type staticRows struct {
+	cols []string
+	data [][]interface{}
+	pos  int
+}
+
+func (s *staticRows) Next() bool {
+	s.pos++
+	return s.pos <= len(s.data)
+}
+func (s *staticRows) Scan(dest ...interface{}) error {
+	for i, v := range s.data[s.pos-1] {
+		*dest[i].(*interface{}) = v
+	}
+	return nil
+}
+func (s *staticRows) Columns() ([]string, error) { return s.cols, nil }
+func (s *staticRows) Close() error               { return nil }
+func (s *staticRows) Err() error                 { return nil }
+
 func captureStdout(f func()) string {
 	old := os.Stdout
 	r, w, _ := os.Pipe()
@@ -168,11 +191,47 @@ func TestDownloadTable_WrapperCoverage(t *testing.T) {
 	}
 	defer db.Close()
 	// This triggers the error path in BuildSelectQuery, which is enough to cover the wrapper
-	err = DownloadTable(db, "table", "/nonexistent/file", false, false, false, false)
+	_, err = DownloadTable(db, "table", ExportOptions{FieldsFile: "/nonexistent/file"})
 	if err == nil || !strings.Contains(err.Error(), "error reading fields file") {
 		t.Errorf("expected error from DownloadTable, got: %v", err)
 	}
+
+	// Unknown format is rejected before touching the database
+	_, err = DownloadTable(db, "table", ExportOptions{Format: "xml"})
+	if err == nil || !strings.Contains(err.Error(), "unknown format") {
+		t.Errorf("expected unknown format error, got: %v", err)
+	}
+}
+
+// stubWriter is a Writer that records what it receives
+type stubWriter struct {
+	openErr  error
+	batchErr error
+	cols     []Column
+	rows     [][]interface{}
+	batches  int
+	closed   bool
+}
+
+func (w *stubWriter) Open(_ context.Context, cols []Column) error {
+	w.cols = cols
+	return w.openErr
 }
+
+func (w *stubWriter) WriteBatch(_ context.Context, rows [][]interface{}) error {
+	if w.batchErr != nil {
+		return w.batchErr
+	}
+	w.rows = append(w.rows, rows...)
+	w.batches++
+	return nil
+}
+
+func (w *stubWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
 func TestDownloadTable_ErrorsAndSuccess(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -181,71 +240,133 @@ func TestDownloadTable_ErrorsAndSuccess(t *testing.T) {
 	defer db.Close()
 
 	// Error from BuildSelectQuery
-	err = DownloadTableWithWriters(db, "table", "/nonexistent/file", false, false, false, false,
-		nil, nil, nil)
+	_, err = DownloadTable(db, "table", ExportOptions{FieldsFile: "/nonexistent/file", Writer: &stubWriter{}})
 	if err == nil || !strings.Contains(err.Error(), "error reading fields file") {
 		t.Errorf("expected error from BuildSelectQuery, got: %v", err)
 	}
 
 	// Error from db.QueryRow(...).Scan(...)
 	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnError(io.EOF)
-	err = DownloadTableWithWriters(db, "table", "", false, false, false, false,
-		nil, nil, func(_ Rows, _ []string, _ string, _, _ bool, _ time.Time) error { return nil })
+	_, err = DownloadTable(db, "table", ExportOptions{Writer: &stubWriter{}})
 	if err == nil || !strings.Contains(err.Error(), "could not get total row count") {
 		t.Errorf("expected error from QueryRow.Scan, got: %v", err)
 	}
 
 	// Error from db.Query(query)
 	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	expectSourceColumns(mock)
 	mock.ExpectQuery(`SELECT \* FROM \[table\]`).WillReturnError(io.EOF)
-	err = DownloadTableWithWriters(db, "table", "", false, false, false, false,
-		nil, nil, func(_ Rows, _ []string, _ string, _, _ bool, _ time.Time) error { return nil })
+	_, err = DownloadTable(db, "table", ExportOptions{Writer: &stubWriter{}})
 	if err == nil || !strings.Contains(err.Error(), "error querying table rows") {
 		t.Errorf("expected error from db.Query, got: %v", err)
 	}
 
 	// Error from rows.Columns() (not supported by sqlmock, so we skip this path)
 
-	// Error from writer function
+	// Error from Writer.Open
 	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	expectSourceColumns(mock)
 	mock.ExpectQuery(`SELECT \* FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"a", "b"}))
-	err = DownloadTableWithWriters(db, "table", "", true, false, false, false,
-		nil, nil, func(_ Rows, _ []string, _ string, _, _ bool, _ time.Time) error {
-			return fmt.Errorf("writer error")
-		})
+	_, err = DownloadTable(db, "table", ExportOptions{Writer: &stubWriter{openErr: fmt.Errorf("writer error")}})
 	if err == nil || !strings.Contains(err.Error(), "writer error") {
 		t.Errorf("expected writer error, got: %v", err)
 	}
 
-	// Success path (default to file output)
+	// Error from Writer.WriteBatch closes the writer
 	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery(`SELECT \* FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"a", "b"}))
-	err = DownloadTableWithWriters(db, "table", "", true, false, false, false,
-		nil, nil, func(_ Rows, _ []string, _ string, _, _ bool, _ time.Time) error { return nil })
-	if err != nil {
-		t.Errorf("expected success, got: %v", err)
+	expectSourceColumns(mock)
+	mock.ExpectQuery(`SELECT \* FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("x", "y"))
+	w := &stubWriter{batchErr: fmt.Errorf("batch error")}
+	_, err = DownloadTable(db, "table", ExportOptions{Writer: w})
+	if err == nil || !strings.Contains(err.Error(), "batch error") {
+		t.Errorf("expected batch error, got: %v", err)
+	}
+	if !w.closed {
+		t.Error("expected writer to be closed after a batch error")
 	}
 
-	// Success path (DuckDB)
+	// Writer declining to start is not an error
 	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	expectSourceColumns(mock)
 	mock.ExpectQuery(`SELECT \* FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"a", "b"}))
-	err = DownloadTableWithWriters(db, "table", "", false, false, false, true,
-		func(_ Rows, _ []string, _ string, _ time.Time) error { return nil },
-		nil,
-		nil)
+	res, err := DownloadTable(db, "table", ExportOptions{Writer: &stubWriter{openErr: ErrAborted}})
+	if err != nil || !res.Aborted {
+		t.Errorf("expected aborted result for aborted writer, got: %+v, %v", res, err)
+	}
+
+	// Success path
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\] WHERE b > 0`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+	expectSourceColumns(mock)
+	mock.ExpectQuery(`SELECT a, b FROM \[table\] WHERE b > 0`).WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("x", 1).AddRow("y", 2))
+	w = &stubWriter{}
+	res, err = DownloadTable(db, "table", ExportOptions{Format: "stub", Writer: w, Fields: []string{"a", "b"}, Where: "b > 0", BatchSize: 1})
 	if err != nil {
-		t.Errorf("expected success for DuckDB, got: %v", err)
+		t.Fatalf("expected success, got: %v", err)
+	}
+	if res.Table != "table" || res.Format != "stub" || res.RowsWritten != 2 || len(res.Columns) != 2 || res.Aborted {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if w.batches != 2 {
+		t.Errorf("expected 2 batches of 1 row, got %d", w.batches)
+	}
+	if len(w.cols) != 2 || w.cols[0].Name != "a" || w.cols[1].Name != "b" {
+		t.Errorf("unexpected columns passed to Open: %+v", w.cols)
+	}
+	if len(w.rows) != 2 || w.rows[1][0] != "y" || w.rows[1][1] != int64(2) {
+		t.Errorf("unexpected rows written: %v", w.rows)
 	}
+	if !w.closed {
+		t.Error("expected writer to be closed")
+	}
+}
 
-	// Success path (SQLite)
-	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery(`SELECT \* FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"a", "b"}))
-	err = DownloadTableWithWriters(db, "table", "", false, false, true, false,
-		nil,
-		func(_ Rows, _ []string, _ string, _ time.Time) error { return nil },
-		nil)
+func TestDownloadTableWithWriterContext_Cancelled(t *testing.T) {
+	db, _, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("expected success for SQLite, got: %v", err)
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &stubWriter{}
+	_, err = DownloadTableContext(ctx, db, "table", ExportOptions{Writer: w})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+	if w.cols != nil {
+		t.Error("expected writer not to be opened")
+	}
+}
+
+// cancelRows yields rows forever and cancels its context on the third row
+type cancelRows struct {
+	stubRows
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelRows) Next() bool {
+	c.n++
+	if c.n == 3 {
+		c.cancel()
+	}
+	return true
+}
+
+func TestRunExport_CancelMidStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &stubWriter{}
+	rows := &cancelRows{cancel: cancel}
+	_, err := runExport(ctx, rows, []Column{{Name: "a"}}, w, exportRun{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+	if len(w.rows) != 0 {
+		t.Errorf("expected the partial batch to be discarded, got %d rows", len(w.rows))
+	}
+	if !w.closed {
+		t.Error("expected writer to be closed after cancellation")
 	}
 }
 
@@ -369,7 +490,10 @@ func TestScanRowValues_AllNil(t *testing.T) {
 	if !rows.Next() {
 		t.Fatal("expected at least one row")
 	}
-	vals := ScanRowValues(rows, columns)
+	vals, err := ScanRowValues(rows, columns)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
 	if vals[0] != nil || vals[1] != nil {
 		t.Errorf("expected all nils, got: %v", vals)
 	}
@@ -454,7 +578,10 @@ func TestScanRowValuesAndMap(t *testing.T) {
 	if !rows.Next() {
 		t.Fatal("expected at least one row")
 	}
-	vals := ScanRowValues(rows, columns)
+	vals, err := ScanRowValues(rows, columns)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
 	if len(vals) != 3 {
 		t.Errorf("expected 3 values, got %d", len(vals))
 	}
@@ -464,14 +591,17 @@ func TestScanRowValuesAndMap(t *testing.T) {
 	if v, ok := vals[1].(string); !ok || v != "foo" {
 		t.Errorf("expected second value to be 'foo', got %v", vals[1])
 	}
-	if v, ok := vals[2].(string); !ok || v != now.Format("2006-01-02") {
-		t.Errorf("expected third value to be formatted date, got %v", vals[2])
+	if v, ok := vals[2].(string); !ok || v != now.Format(time.RFC3339Nano) {
+		t.Errorf("expected third value to be the full timestamp, got %v", vals[2])
 	}
 
 	if !rows.Next() {
 		t.Fatal("expected second row")
 	}
-	vals2 := ScanRowValues(rows, columns)
+	vals2, err := ScanRowValues(rows, columns)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
 	if vals2[0] != nil {
 		t.Errorf("expected nil for first value, got %v", vals2[0])
 	}
@@ -494,7 +624,10 @@ func TestScanRowValuesAndMap(t *testing.T) {
 	if !rows2.Next() {
 		t.Fatal("expected at least one row")
 	}
-	m := ScanRowMap(rows2, []string{"a", "b"})
+	m, err := ScanRowMap(rows2, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
 	if m["a"] != "x" || m["b"] != int64(123) {
 		t.Errorf("unexpected map values: %v", m)
 	}
@@ -612,3 +745,13 @@ func TestWriteFileOutput_EdgeCases(t *testing.T) {
 		t.Errorf("expected error for file exists, got: %v", err)
 	}
 }
+
+// expectSourceColumns expects the INFORMATION_SCHEMA query DownloadTable uses
+// to find the columns to convert on the server, returning cols as name/type pairs.
+func expectSourceColumns(mock sqlmock.Sqlmock, cols ...string) {
+	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE"})
+	for i := 0; i+1 < len(cols); i += 2 {
+		rows.AddRow(cols[i], cols[i+1])
+	}
+	mock.ExpectQuery(`SELECT COLUMN_NAME, DATA_TYPE FROM INFORMATION_SCHEMA.COLUMNS`).WillReturnRows(rows)
+}
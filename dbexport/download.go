@@ -1,100 +1,347 @@
 package dbexport
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"getmssql/i18n"
 	"os"
 	"strings"
 	"time"
 )
 
-type DuckDBWriter func(rows Rows, cols []string, table string, start time.Time) error
-type SQLiteWriter func(rows Rows, cols []string, table string, start time.Time) error
-type FileWriter func(rows Rows, cols []string, table string, asTSV, asCSV bool, start time.Time) error
-
-// DownloadTableWithWriters allows dependency injection for writer functions (for testing).
-func DownloadTableWithWriters(
-	db *sql.DB,
-	table string,
-	fieldsFile string,
-	asTSV, asCSV, asSQLite, asDuckDB bool,
-	duckDBWriter DuckDBWriter,
-	sqliteWriter SQLiteWriter,
-	fileWriter FileWriter,
-) error {
+// defaultBatchSize is the number of rows handed to a Writer per WriteBatch
+// call when ExportOptions.BatchSize is not set.
+const defaultBatchSize = 10000
+
+// DownloadTable exports a table as described by opts and reports what was written.
+func DownloadTable(db *sql.DB, table string, opts ExportOptions) (*ExportResult, error) {
+	return DownloadTableContext(context.Background(), db, table, opts)
+}
+
+// DownloadTableContext is like DownloadTable but runs the MSSQL queries and the
+// Writer under ctx. Cancelling ctx stops the server-side query and the writer,
+// and the returned error wraps ctx.Err().
+func DownloadTableContext(ctx context.Context, db *sql.DB, table string, opts ExportOptions) (*ExportResult, error) {
 	start := time.Now()
-	query, err := BuildSelectQuery(table, fieldsFile)
+	format := opts.Format
+	w := opts.Writer
+	if w == nil {
+		if format == "" {
+			format = "json"
+		}
+		wopts := opts.WriterOptions
+		wopts.Table = table
+		var err error
+		if w, err = NewWriter(format, wopts); err != nil {
+			return nil, err
+		}
+	}
+	q, err := newTableQuery(table, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	// Get total row count
 	var totalRows int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM [%s]", table)
-	err = db.QueryRow(countQuery).Scan(&totalRows)
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", quoteTable(table), whereClause(opts.Where))
+	err = db.QueryRowContext(ctx, countQuery).Scan(&totalRows)
 	if err != nil {
-		return fmt.Errorf("could not get total row count: %w", err)
+		return nil, i18n.Errorf("download.count_error", ClassifyError(err))
 	}
 
-	fmt.Printf("Starting download of table '%s'%s... ", table, func() string {
-		if fieldsFile != "" {
-			return fmt.Sprintf(" with fields from '%s'", fieldsFile)
-		} else {
-			return ""
-		}
-	}())
-	fmt.Printf("(total rows: %d)\n", totalRows)
+	run := exportRun{
+		batchSize: opts.BatchSize,
+		progress:  opts.Progress,
+		rowErrs:   newRowErrorHandler(opts.OnRowError, opts.RejectFile),
+		conv:      newValueConverter(opts.WriterOptions),
+	}
+	progress := run.reporter()
+	progress.Start(table, int64(totalRows))
 
-	rows, err := db.Query(query)
+	rows, cols, err := q.run(ctx, db, spatialEncodingFor(w))
 	if err != nil {
-		return fmt.Errorf("error querying table rows: %w", err)
+		progress.Finish(0, err)
+		return nil, err
 	}
 	defer rows.Close()
+	res, err := runExport(ctx, rows, cols, w, run)
+	if err != nil {
+		return nil, err
+	}
+	res.Table = table
+	res.Format = format
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// tableQuery is the SELECT of an export: the table, the fields and filter to
+// read and the column type overrides to apply.
+type tableQuery struct {
+	table       string
+	fields      []string
+	where       string
+	columnTypes map[string]ColumnType
+}
+
+// newTableQuery returns the query opts describe, reading its fields file and
+// types file when given.
+func newTableQuery(table string, opts ExportOptions) (*tableQuery, error) {
+	if err := checkTableName(table); err != nil {
+		return nil, err
+	}
+	q := &tableQuery{table: table, fields: opts.Fields, where: opts.Where, columnTypes: opts.ColumnTypes}
+	if len(q.fields) == 0 && opts.FieldsFile != "" {
+		var err error
+		if q.fields, err = readFieldsFile(opts.FieldsFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(q.columnTypes) == 0 && opts.TypesFile != "" {
+		var err error
+		if q.columnTypes, err = ReadTypesFile(opts.TypesFile); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
 
-	cols, err := rows.Columns()
+// run looks up the declared column types, runs the SELECT with the
+// server-side conversions they need and resolves the result columns.
+func (q *tableQuery) run(ctx context.Context, db *sql.DB, spatial spatialEncoding) (*sql.Rows, []Column, error) {
+	source, err := sourceColumnsContext(ctx, db, q.table)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := db.QueryContext(ctx, selectQuery(q.table, selectList(q.fields, source, spatial), q.where))
 	if err != nil {
-		return fmt.Errorf("error getting columns: %w", err)
+		return nil, nil, i18n.Errorf("download.query_error", ClassifyError(err))
+	}
+	names, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, nil, i18n.Errorf("download.columns_error", ClassifyError(err))
+	}
+	cols := resolveColumns(rows, names)
+	applySourceTypes(cols, source, spatial)
+	if err := applyColumnTypes(cols, q.columnTypes); err != nil {
+		rows.Close()
+		return nil, nil, err
 	}
+	return rows, cols, nil
+}
+
+// exportRun holds the settings of one export that runExport needs besides the
+// rows and the writer. The zero value is usable.
+type exportRun struct {
+	batchSize int              // rows per WriteBatch; defaultBatchSize when <= 0
+	progress  ProgressReporter // nil reports nothing
+	rowErrs   *rowErrorHandler // nil fails the export on the first bad row
+	conv      valueConverter   // converts values for writers without NativeValues
+}
 
-	var writeErr error
-	switch {
-	case asDuckDB:
-		writeErr = duckDBWriter(rows, cols, table, start)
-	case asSQLite:
-		writeErr = sqliteWriter(rows, cols, table, start)
-	default:
-		writeErr = fileWriter(rows, cols, table, asTSV, asCSV, start)
+func (r exportRun) reporter() ProgressReporter {
+	if r.progress == nil {
+		return NoProgress
 	}
-	if writeErr != nil {
-		return writeErr
+	return r.progress
+}
+
+// runExport opens w, streams all rows into it in batches and closes it,
+// reporting to run's progress reporter, whose Start has already been called.
+// A Writer declining to start (ErrAborted) yields a result with Aborted set.
+func runExport(ctx context.Context, rows Rows, cols []Column, w Writer, run exportRun) (*ExportResult, error) {
+	progress, rowErrs := run.reporter(), run.rowErrs
+	res := &ExportResult{Columns: cols}
+	if err := w.Open(ctx, cols); err != nil {
+		progress.Finish(0, err)
+		if errors.Is(err, ErrAborted) {
+			res.Aborted = true
+			return res, nil
+		}
+		return nil, err
+	}
+	rowCount, err := writeRows(ctx, rows, cols, w, run)
+	if closeErr := rowErrs.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		w.Close()
+		progress.Finish(int64(rowCount), err)
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		progress.Finish(int64(rowCount), err)
+		return nil, err
 	}
+	progress.Finish(int64(rowCount), nil)
+	res.RowsWritten = int64(rowCount)
+	res.RowsRejected, res.RejectFile = rowErrs.rejected()
+	if l, ok := w.(Locator); ok {
+		res.Output = l.Location()
+	}
+	if c, ok := w.(ByteCounter); ok {
+		res.BytesWritten = c.BytesWritten()
+	}
+	return res, nil
+}
+
+// runLegacyExport backs the WriteFileOutput, WriteSQLite and WriteDuckDB helpers:
+// it exports rows with default options and prints the summary line.
+func runLegacyExport(rows Rows, cols []string, table string, w Writer, start time.Time) error {
+	progress := NewTerminalProgress(os.Stdout)
+	progress.Start(table, 0)
+	res, err := runExport(context.Background(), rows, resolveColumns(rows, cols), w, exportRun{progress: progress})
+	if err != nil || res.Aborted {
+		return err
+	}
+	res.Table = table
+	res.Duration = time.Since(start)
+	fmt.Println(i18n.T("download.done", res.Table, res.Output, res.Duration))
 	return nil
 }
 
-// DownloadTable exports a table to the selected format using the default writers.
-func DownloadTable(db *sql.DB, table string, fieldsFile string, asTSV, asCSV, asSQLite, asDuckDB bool) error {
-	return DownloadTableWithWriters(db, table, fieldsFile, asTSV, asCSV, asSQLite, asDuckDB, WriteDuckDBRows, WriteSQLite, WriteFileOutputRows)
+// writeRows scans rows and hands them to w in batches of run.batchSize.
+// It returns the number of rows written.
+func writeRows(ctx context.Context, rows Rows, cols []Column, w Writer, run exportRun) (int, error) {
+	batchSize := run.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	progress := run.reporter()
+	conv := &run.conv
+	if nv, ok := w.(NativeValuer); ok && nv.NativeValues() {
+		conv = nil
+	}
+	preparer, _ := w.(rowPreparer)
+	batch := make([][]interface{}, 0, batchSize)
+	rowCount := 0
+	var ordinal int64
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return rowCount - len(batch), i18n.Errorf("download.cancelled", err)
+		}
+		ordinal++
+		vals, err := scanRow(rows, cols, conv, preparer)
+		if err != nil {
+			if err := run.rowErrs.handle(&RowError{Row: ordinal, Err: err}); err != nil {
+				return rowCount - len(batch), err
+			}
+			continue
+		}
+		batch = append(batch, vals)
+		rowCount++
+		if len(batch) == batchSize {
+			if err := w.WriteBatch(ctx, batch); err != nil {
+				return rowCount - len(batch), err
+			}
+			batch = batch[:0]
+		}
+		if rowCount%progressInterval == 0 {
+			progress.Update(int64(rowCount))
+		}
+	}
+	// A cancelled query ends the result set early; report the cancellation, not the driver error
+	if err := ctx.Err(); err != nil {
+		return rowCount - len(batch), i18n.Errorf("download.cancelled", err)
+	}
+	if err := rows.Err(); err != nil {
+		return rowCount - len(batch), i18n.Errorf("rows.error", ClassifyError(err))
+	}
+	if len(batch) > 0 {
+		if err := w.WriteBatch(ctx, batch); err != nil {
+			return rowCount - len(batch), err
+		}
+	}
+	return rowCount, nil
+}
+
+// scanRow scans the current row and prepares its values: it applies the
+// column type overrides, converts the values with conv unless it is nil and
+// hands them to preparer when there is one.
+func scanRow(rows Rows, cols []Column, conv *valueConverter, preparer rowPreparer) ([]interface{}, error) {
+	vals, err := scanRawValues(rows, len(cols))
+	if err == nil {
+		err = coerceRow(cols, vals)
+	}
+	if err == nil && conv != nil {
+		err = conv.convertRow(cols, vals)
+	}
+	if err == nil && preparer != nil {
+		err = preparer.prepareRow(vals)
+	}
+	return vals, err
 }
 
 // BuildSelectQuery builds a SELECT query for the given table and optional fields file.
+// It does not look up the column types; see BuildSelectQueryContext.
 func BuildSelectQuery(table, fieldsFile string) (string, error) {
+	var fields []string
 	if fieldsFile != "" {
-		data, err := os.ReadFile(fieldsFile)
-		if err != nil {
-			return "", fmt.Errorf("error reading fields file: %w", err)
+		var err error
+		if fields, err = readFieldsFile(fieldsFile); err != nil {
+			return "", err
 		}
-		var fields []string
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if trimmed != "" {
-				fields = append(fields, trimmed)
-			}
+	}
+	return selectQuery(table, fields, ""), nil
+}
+
+// BuildSelectQueryContext is like BuildSelectQuery but resolves the column
+// types of table first, and reads the types the driver cannot scan through
+// server-side conversions: xml as nvarchar(max), hierarchyid with ToString(),
+// sql_variant as text plus a <column>_basetype column, and geography and
+// geometry as WKT. This applies to SELECT * and to fields-file selections.
+func BuildSelectQueryContext(ctx context.Context, db *sql.DB, table, fieldsFile string) (string, error) {
+	if err := checkTableName(table); err != nil {
+		return "", err
+	}
+	var fields []string
+	if fieldsFile != "" {
+		var err error
+		if fields, err = readFieldsFile(fieldsFile); err != nil {
+			return "", err
 		}
-		if len(fields) == 0 {
-			return "", fmt.Errorf("no fields found in file: %s", fieldsFile)
+	}
+	source, err := sourceColumnsContext(ctx, db, table)
+	if err != nil {
+		return "", err
+	}
+	return selectQuery(table, selectList(fields, source, spatialWKT), ""), nil
+}
+
+// readFieldsFile reads a fields file with one column name per line.
+func readFieldsFile(fieldsFile string) ([]string, error) {
+	data, err := os.ReadFile(fieldsFile)
+	if err != nil {
+		return nil, i18n.Errorf("fieldsfile.read_error", err)
+	}
+	var fields []string
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" {
+			fields = append(fields, trimmed)
 		}
-		return fmt.Sprintf("SELECT %s FROM [%s]", strings.Join(fields, ", "), table), nil
 	}
-	return fmt.Sprintf("SELECT * FROM [%s]", table), nil
+	if len(fields) == 0 {
+		return nil, i18n.Errorf("fieldsfile.empty", fieldsFile)
+	}
+	return fields, nil
+}
+
+// selectQuery builds the SELECT for table with the given fields (all when empty) and filter.
+func selectQuery(table string, fields []string, where string) string {
+	list := "*"
+	if len(fields) > 0 {
+		list = strings.Join(fields, ", ")
+	}
+	return fmt.Sprintf("SELECT %s FROM %s%s", list, quoteTable(table), whereClause(where))
+}
+
+// whereClause renders an optional filter as a WHERE clause.
+func whereClause(where string) string {
+	if strings.TrimSpace(where) == "" {
+		return ""
+	}
+	return " WHERE " + where
 }
@@ -0,0 +1,69 @@
+package dbexport
+
+import (
+	"errors"
+	"getmssql/i18n"
+)
+
+// Error classes for failures reported by SQL Server. Errors returned by this
+// package wrap one of these when the server error number is recognised, so
+// callers can test for them with errors.Is.
+var (
+	ErrTableNotFound    error = i18n.NewError("err.table_not_found")
+	ErrColumnNotFound   error = i18n.NewError("err.column_not_found")
+	ErrPermissionDenied error = i18n.NewError("err.permission_denied")
+	ErrLoginFailed      error = i18n.NewError("err.login_failed")
+	ErrDatabaseNotFound error = i18n.NewError("err.database_not_found")
+)
+
+// serverErrorClasses maps SQL Server error numbers to error classes.
+var serverErrorClasses = map[int32]error{
+	208:   ErrTableNotFound,    // Invalid object name
+	207:   ErrColumnNotFound,   // Invalid column name
+	4104:  ErrColumnNotFound,   // The multi-part identifier could not be bound
+	229:   ErrPermissionDenied, // Permission denied on object
+	230:   ErrPermissionDenied, // Permission denied on column
+	262:   ErrPermissionDenied, // Permission denied in database
+	297:   ErrPermissionDenied, // User does not have permission to perform this action
+	300:   ErrPermissionDenied, // VIEW SERVER STATE / VIEW DEFINITION permission denied
+	18456: ErrLoginFailed,      // Login failed for user
+	18452: ErrLoginFailed,      // Login is from an untrusted domain
+	4060:  ErrDatabaseNotFound, // Cannot open database requested by the login
+	911:   ErrDatabaseNotFound, // Database does not exist
+}
+
+// sqlErrorNumberer is implemented by go-mssqldb's mssql.Error.
+type sqlErrorNumberer interface {
+	SQLErrorNumber() int32
+}
+
+// ServerError is a SQL Server error together with its error class.
+// errors.Is matches both the class (e.g. ErrTableNotFound) and anything in the
+// original error chain, and errors.As still finds the driver's mssql.Error.
+type ServerError struct {
+	Class  error
+	Number int32
+	Err    error
+}
+
+func (e *ServerError) Error() string { return e.Err.Error() }
+
+func (e *ServerError) Unwrap() []error { return []error{e.Class, e.Err} }
+
+// ClassifyError wraps err in a *ServerError when it carries a SQL Server error
+// number with a known class. Other errors, and nil, are returned unchanged.
+func ClassifyError(err error) error {
+	var numbered sqlErrorNumberer
+	if err == nil || !errors.As(err, &numbered) {
+		return err
+	}
+	var already *ServerError
+	if errors.As(err, &already) {
+		return err
+	}
+	class, ok := serverErrorClasses[numbered.SQLErrorNumber()]
+	if !ok {
+		return err
+	}
+	return &ServerError{Class: class, Number: numbered.SQLErrorNumber(), Err: err}
+}
@@ -0,0 +1,94 @@
+package dbexport
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		number int32
+		class  error
+	}{
+		{208, ErrTableNotFound},
+		{207, ErrColumnNotFound},
+		{229, ErrPermissionDenied},
+		{18456, ErrLoginFailed},
+		{4060, ErrDatabaseNotFound},
+	}
+	for _, c := range cases {
+		orig := mssql.Error{Number: c.number, Message: "server message"}
+		err := fmt.Errorf("context: %w", ClassifyError(fmt.Errorf("wrapped: %w", orig)))
+		if !errors.Is(err, c.class) {
+			t.Errorf("error %d: expected errors.Is(%v)", c.number, c.class)
+		}
+		var srvErr *ServerError
+		if !errors.As(err, &srvErr) || srvErr.Number != c.number {
+			t.Errorf("error %d: expected *ServerError with the number, got %v", c.number, srvErr)
+		}
+		var drvErr mssql.Error
+		if !errors.As(err, &drvErr) || drvErr.Number != c.number {
+			t.Errorf("error %d: expected the driver error to stay in the chain", c.number)
+		}
+		if !strings.Contains(err.Error(), "server message") {
+			t.Errorf("error %d: expected the server message, got %q", c.number, err.Error())
+		}
+		// Classifying twice does not nest
+		if again := ClassifyError(err); again != err {
+			t.Errorf("error %d: expected classified error to be returned unchanged", c.number)
+		}
+	}
+}
+
+func TestClassifyError_Unclassified(t *testing.T) {
+	if ClassifyError(nil) != nil {
+		t.Error("expected nil for nil")
+	}
+	plain := errors.New("invalid object name 'foo'")
+	if ClassifyError(plain) != plain {
+		t.Error("expected error without a server number to be returned unchanged")
+	}
+	unknown := mssql.Error{Number: 50000, Message: "custom"}
+	err := ClassifyError(unknown)
+	var srvErr *ServerError
+	if _, ok := err.(mssql.Error); !ok || errors.As(err, &srvErr) {
+		t.Errorf("expected unknown number to be returned unchanged, got %#v", err)
+	}
+}
+
+func TestDownloadTable_TableNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[missing\]`).WillReturnError(mssql.Error{Number: 208, Message: "Invalid object name 'missing'."})
+	_, err = DownloadTable(db, "missing", ExportOptions{Writer: &stubWriter{}})
+	if !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("expected ErrTableNotFound, got: %v", err)
+	}
+}
+
+func TestListFields_TableNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE FROM INFORMATION_SCHEMA.COLUMNS`).WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE"}))
+	err = ListFields(db, "missing")
+	if !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("expected ErrTableNotFound, got: %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES`).WillReturnError(mssql.Error{Number: 229, Message: "The SELECT permission was denied"})
+	err = ListTables(db)
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got: %v", err)
+	}
+}
@@ -2,73 +2,245 @@ package dbexport
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"getmssql/i18n"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // ScanRowValues scans a row into a slice of values, converting types as needed.
-func ScanRowValues(rows Rows, cols []string) []interface{} {
-	columns := make([]interface{}, len(cols))
-	columnPointers := make([]interface{}, len(cols))
-	for i := range columns {
-		columnPointers[i] = &columns[i]
-	}
-	if err := rows.Scan(columnPointers...); err != nil {
-		panic(fmt.Sprintf("Error scanning row: %v", err))
-	}
-	vals := make([]interface{}, len(cols))
-	for i := range cols {
-		val := columnPointers[i].(*interface{})
-		v := *val
-		switch t := v.(type) {
-		case time.Time:
-			vals[i] = t.Format("2006-01-02")
-		case []uint8:
-			s := string(t)
-			if intVal, err := strconv.ParseInt(s, 10, 64); err == nil {
-				vals[i] = intVal
-			} else if floatVal, err := strconv.ParseFloat(s, 64); err == nil {
-				vals[i] = floatVal
-			} else {
-				vals[i] = s
-			}
-		default:
-			vals[i] = v
-		}
+// Column types are taken from rows when it reports them (as *sql.Rows does).
+func ScanRowValues(rows Rows, cols []string) ([]interface{}, error) {
+	vals, err := scanRawValues(rows, len(cols))
+	if err != nil {
+		return nil, err
+	}
+	var conv valueConverter
+	if err := conv.convertRow(resolveColumns(rows, cols), vals); err != nil {
+		return nil, err
 	}
-	return vals
+	return vals, nil
 }
 
 // ScanRowMap scans a row into a map of column names to values, converting types as needed.
-func ScanRowMap(rows *sql.Rows, cols []string) map[string]interface{} {
-	columns := make([]interface{}, len(cols))
-	columnPointers := make([]interface{}, len(cols))
-	for i := range columns {
-		columnPointers[i] = &columns[i]
+func ScanRowMap(rows *sql.Rows, cols []string) (map[string]interface{}, error) {
+	vals, err := scanRawValues(rows, len(cols))
+	if err != nil {
+		return nil, err
 	}
-	if err := rows.Scan(columnPointers...); err != nil {
-		panic(fmt.Sprintf("Error scanning row: %v", err))
+	var conv valueConverter
+	if err := conv.convertRow(resolveColumns(rows, cols), vals); err != nil {
+		return nil, err
 	}
 	rowMap := make(map[string]interface{})
 	for i, colName := range cols {
-		val := columnPointers[i].(*interface{})
-		v := *val
-		switch t := v.(type) {
-		case time.Time:
-			rowMap[colName] = t.Format("2006-01-02")
-		case []uint8:
-			s := string(t)
-			if intVal, err := strconv.ParseInt(s, 10, 64); err == nil {
-				rowMap[colName] = intVal
-			} else if floatVal, err := strconv.ParseFloat(s, 64); err == nil {
-				rowMap[colName] = floatVal
-			} else {
-				rowMap[colName] = s
-			}
-		default:
-			rowMap[colName] = v
+		rowMap[colName] = vals[i]
+	}
+	return rowMap, nil
+}
+
+// scanRawValues scans a row of n columns into the values returned by the driver.
+func scanRawValues(rows Rows, n int) ([]interface{}, error) {
+	vals := make([]interface{}, n)
+	pointers := make([]interface{}, n)
+	for i := range vals {
+		pointers[i] = &vals[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, i18n.Errorf("rows.scan_error", err)
+	}
+	return vals, nil
+}
+
+// Values accepted by WriterOptions.DatetimeFormat besides a Go time layout.
+const (
+	// DatetimeISO writes ISO 8601 at the full precision of the source type.
+	DatetimeISO = "iso"
+	// DatetimeDateOnly writes only the date, as older versions did.
+	DatetimeDateOnly = "date"
+)
+
+// ValidateDatetimeFormat checks a --datetime-format value: DatetimeISO,
+// DatetimeDateOnly, or a Go time layout such as "2006-01-02 15:04:05".
+func ValidateDatetimeFormat(format string) error {
+	switch format {
+	case "", DatetimeISO, DatetimeDateOnly:
+		return nil
+	}
+	// A layout without any reference element formats to itself
+	if time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(format) == format {
+		return i18n.Errorf("values.bad_datetime_format", format)
+	}
+	return nil
+}
+
+// isoLayouts holds the ISO 8601 layout of each MSSQL date and time type, with
+// as many fractional digits as the type can store. The driver does not report
+// the declared scale of datetime2, time and datetimeoffset, so they use the
+// maximum of 7.
+var isoLayouts = map[string]string{
+	"DATE":           "2006-01-02",
+	"TIME":           "15:04:05.0000000",
+	"SMALLDATETIME":  "2006-01-02T15:04:05",
+	"DATETIME":       "2006-01-02T15:04:05.000",
+	"DATETIME2":      "2006-01-02T15:04:05.0000000",
+	"DATETIMEOFFSET": "2006-01-02T15:04:05.0000000Z07:00",
+}
+
+// valueConverter turns driver values into the values written by the text
+// formats: dates and times become strings and DECIMAL, NUMERIC and MONEY
+// values, which the driver sends as text, become exact json.Numbers.
+// The zero value writes ISO 8601 and keeps offsets.
+type valueConverter struct {
+	datetimeFormat string         // DatetimeISO (default), DatetimeDateOnly or a Go layout
+	location       *time.Location // zone for values with an offset; nil keeps it
+	binary         BinaryEncoding // text encoding of binary values; BinaryAuto keeps the bytes
+}
+
+func newValueConverter(opts WriterOptions) valueConverter {
+	return valueConverter{datetimeFormat: opts.DatetimeFormat, location: opts.TimeZone, binary: opts.BinaryEncoding}
+}
+
+// newTextConverter is newValueConverter for writers that store values as
+// database text, which hold binary values as hex unless told otherwise.
+func newTextConverter(opts WriterOptions) valueConverter {
+	conv := newValueConverter(opts)
+	if conv.binary == "" || conv.binary == BinaryAuto {
+		conv.binary = BinaryHex
+	}
+	return conv
+}
+
+// convertRow converts a row of driver values in place.
+func (c valueConverter) convertRow(cols []Column, vals []interface{}) error {
+	for i, v := range vals {
+		val, err := c.convert(cols[i], v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", cols[i].Name, err)
 		}
+		vals[i] = val
+	}
+	return nil
+}
+
+// convert returns the text format value of v, read from a column of type col.
+func (c valueConverter) convert(col Column, v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return c.formatTime(col, t), nil
+	case []uint8:
+		return c.convertBytes(col, t)
+	case bool:
+		if col.override != nil {
+			return col.override.formatBool(t), nil
+		}
+	}
+	return v, nil
+}
+
+// jsonNumberRe matches the JSON number grammar.
+var jsonNumberRe = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// isDecimalType reports whether an MSSQL type is an exact decimal type, whose
+// values the driver sends as their decimal text.
+func isDecimalType(databaseType string) bool {
+	switch strings.ToUpper(databaseType) {
+	case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY":
+		return true
+	}
+	return false
+}
+
+// isBinaryType reports whether an MSSQL type holds raw bytes.
+func isBinaryType(databaseType string) bool {
+	switch strings.ToUpper(databaseType) {
+	case "BINARY", "VARBINARY", "IMAGE", "TIMESTAMP", "ROWVERSION":
+		return true
+	}
+	return false
+}
+
+// convertBytes converts a value the driver sent as bytes, according to the
+// column type. Decimals keep their exact digits as a json.Number, which JSON
+// writes as a number and the other formats as its text, uniqueidentifiers
+// become canonical UUID text and binary values are encoded as configured.
+// Only when the driver reports no type is the value recognised by its content.
+func (c valueConverter) convertBytes(col Column, b []byte) (interface{}, error) {
+	s := string(b)
+	switch typ := strings.ToUpper(col.DatabaseType); {
+	case isBinaryType(typ):
+		return c.encodeBinary(b), nil
+	case isDecimalType(typ):
+		if jsonNumberRe.MatchString(s) {
+			return json.Number(s), nil
+		}
+		return s, nil
+	case typ == "UNIQUEIDENTIFIER":
+		return mssqlUUID(b)
+	case typ != "":
+		return s, nil
+	}
+	if intVal, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return intVal, nil
+	}
+	if jsonNumberRe.MatchString(s) {
+		return json.Number(s), nil
+	}
+	return s, nil
+}
+
+// formatTime formats t according to the column type and the converter settings.
+// Only values that carry a real offset (datetimeoffset, or an unknown type) are
+// moved to the converter's location; datetime and datetime2 have no zone.
+func (c valueConverter) formatTime(col Column, t time.Time) string {
+	typ := strings.ToUpper(col.DatabaseType)
+	layout, known := isoLayouts[typ]
+	if c.location != nil && (typ == "DATETIMEOFFSET" || !known) {
+		t = t.In(c.location)
+	}
+	format := c.datetimeFormat
+	if col.override != nil && col.override.Format != "" {
+		format = col.override.Format
+	}
+	switch format {
+	case "", DatetimeISO:
+		if !known {
+			layout = time.RFC3339Nano
+		}
+	case DatetimeDateOnly:
+		layout = "2006-01-02"
+	default:
+		layout = format
+	}
+	return t.Format(layout)
+}
+
+// encodeBinary returns b as text in the configured encoding. With BinaryAuto
+// the bytes are kept, and each writer applies its default: base64 for JSON,
+// hex for the delimited formats.
+func (c valueConverter) encodeBinary(b []byte) interface{} {
+	switch c.binary {
+	case BinaryBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case BinaryHex:
+		return hex.EncodeToString(b)
+	}
+	return b
+}
+
+// mssqlUUID formats a uniqueidentifier as sent by SQL Server as lowercase
+// canonical UUID text. SQL Server stores the first three groups little-endian,
+// so their bytes are swapped; the last 8 bytes are in order.
+func mssqlUUID(b []byte) (string, error) {
+	if len(b) != 16 {
+		return "", i18n.Errorf("values.bad_uuid", len(b))
 	}
-	return rowMap
+	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%02x%02x-%02x%02x%02x%02x%02x%02x",
+		b[3], b[2], b[1], b[0], b[5], b[4], b[7], b[6],
+		b[8], b[9], b[10], b[11], b[12], b[13], b[14], b[15]), nil
 }
@@ -0,0 +1,225 @@
+package dbexport
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueConverter_DateTimes(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	naive := time.Date(2024, 3, 9, 23, 59, 58, 123456700, time.UTC)
+	offset := time.Date(2024, 3, 9, 23, 59, 58, 123456700, time.FixedZone("", 2*3600))
+	cases := []struct {
+		name   string
+		conv   valueConverter
+		typ    string
+		value  time.Time
+		expect string
+	}{
+		{"date", valueConverter{}, "DATE", naive, "2024-03-09"},
+		{"time", valueConverter{}, "TIME", naive, "23:59:58.1234567"},
+		{"smalldatetime", valueConverter{}, "SMALLDATETIME", naive, "2024-03-09T23:59:58"},
+		{"datetime", valueConverter{}, "DATETIME", naive, "2024-03-09T23:59:58.123"},
+		{"datetime2", valueConverter{}, "DATETIME2", naive, "2024-03-09T23:59:58.1234567"},
+		{"datetimeoffset", valueConverter{}, "DATETIMEOFFSET", offset, "2024-03-09T23:59:58.1234567+02:00"},
+		{"unknown type", valueConverter{}, "", offset, "2024-03-09T23:59:58.1234567+02:00"},
+		{"date only", valueConverter{datetimeFormat: DatetimeDateOnly}, "DATETIME2", naive, "2024-03-09"},
+		{"layout", valueConverter{datetimeFormat: "02/01/2006 15:04"}, "DATETIME2", naive, "09/03/2024 23:59"},
+		{"offset to zone", valueConverter{location: ny}, "DATETIMEOFFSET", offset, "2024-03-09T16:59:58.1234567-05:00"},
+		{"naive keeps wall clock", valueConverter{location: ny}, "DATETIME2", naive, "2024-03-09T23:59:58.1234567"},
+		{"utc", valueConverter{location: time.UTC}, "DATETIMEOFFSET", offset, "2024-03-09T21:59:58.1234567Z"},
+	}
+	for _, c := range cases {
+		got, err := c.conv.convert(Column{DatabaseType: c.typ}, c.value)
+		if err != nil || got != c.expect {
+			t.Errorf("%s: got %v, want %s (err: %v)", c.name, got, c.expect, err)
+		}
+	}
+}
+
+func TestValidateDatetimeFormat(t *testing.T) {
+	for _, ok := range []string{"", "iso", "date", "2006-01-02 15:04:05", time.RFC3339} {
+		if err := ValidateDatetimeFormat(ok); err != nil {
+			t.Errorf("ValidateDatetimeFormat(%q): unexpected error %v", ok, err)
+		}
+	}
+	if err := ValidateDatetimeFormat("yyyy-mm-dd"); err == nil {
+		t.Error("expected error for a layout without reference elements")
+	}
+}
+
+func TestValueConverter_Decimals(t *testing.T) {
+	var conv valueConverter
+	cases := []struct {
+		typ    string
+		in     string
+		expect interface{}
+	}{
+		{"DECIMAL", "1234567890123456789012345678.1234567890", json.Number("1234567890123456789012345678.1234567890")},
+		{"NUMERIC", "-0.0000000001", json.Number("-0.0000000001")},
+		{"MONEY", "922337203685477.5807", json.Number("922337203685477.5807")},
+		{"SMALLMONEY", "42.0000", json.Number("42.0000")},
+		// Typed non-decimal columns are never guessed as numbers
+		{"VARCHAR", "42", "42"},
+		// Without a type, numbers are recognised but keep their digits
+		{"", "42", int64(42)},
+		{"", "0.1000000000000000055511151231257827", json.Number("0.1000000000000000055511151231257827")},
+		{"", "Inf", "Inf"},
+		{"", "abc", "abc"},
+	}
+	for _, c := range cases {
+		got, err := conv.convert(Column{DatabaseType: c.typ}, []byte(c.in))
+		if err != nil || got != c.expect {
+			t.Errorf("convert(%s, %q) = %#v, want %#v (err: %v)", c.typ, c.in, got, c.expect, err)
+		}
+	}
+}
+
+func TestFileWriter_ExactDecimals(t *testing.T) {
+	cols := []Column{{Name: "amount", DatabaseType: "DECIMAL", Precision: 38, Scale: 10}, {Name: "fee", DatabaseType: "MONEY"}}
+	data := [][]interface{}{{[]byte("12345678901234567890.1234567890"), []byte("0.1000")}}
+	for format, want := range map[string]string{
+		"json": `[{"amount":12345678901234567890.1234567890,"fee":0.1000}]`,
+		"csv":  "amount,fee\n12345678901234567890.1234567890,0.1000\n",
+		"tsv":  "amount\tfee\n12345678901234567890.1234567890\t0.1000\n",
+	} {
+		out := filepath.Join(t.TempDir(), "out."+format)
+		w := newFileWriter(WriterOptions{Table: "t", Output: out}, format)
+		rows := &staticRows{cols: columnNames(cols), data: data}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+			t.Fatalf("%s: export failed: %v", format, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q (err: %v)", format, got, want, err)
+		}
+	}
+}
+
+// guidBytes is 6F9619FF-8B86-D011-B42D-00C04FC964FF as SQL Server sends it.
+var guidBytes = []byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff}
+
+func TestValueConverter_UUID(t *testing.T) {
+	var conv valueConverter
+	got, err := conv.convert(Column{DatabaseType: "UNIQUEIDENTIFIER"}, guidBytes)
+	if err != nil || got != "6f9619ff-8b86-d011-b42d-00c04fc964ff" {
+		t.Errorf("got %v, want 6f9619ff-8b86-d011-b42d-00c04fc964ff (err: %v)", got, err)
+	}
+	if _, err := conv.convert(Column{DatabaseType: "UNIQUEIDENTIFIER"}, guidBytes[:15]); err == nil {
+		t.Error("expected error for a 15-byte uniqueidentifier")
+	}
+	if got, _ := conv.convert(Column{DatabaseType: "UNIQUEIDENTIFIER"}, nil); got != nil {
+		t.Errorf("NULL uniqueidentifier: got %v, want nil", got)
+	}
+}
+
+func TestFileWriter_UUIDs(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "UNIQUEIDENTIFIER"}}
+	data := [][]interface{}{{guidBytes}}
+	for format, want := range map[string]string{
+		"json": `[{"id":"6f9619ff-8b86-d011-b42d-00c04fc964ff"}]`,
+		"csv":  "id\n6f9619ff-8b86-d011-b42d-00c04fc964ff\n",
+		"tsv":  "id\n6f9619ff-8b86-d011-b42d-00c04fc964ff\n",
+	} {
+		out := filepath.Join(t.TempDir(), "out."+format)
+		w := newFileWriter(WriterOptions{Table: "t", Output: out}, format)
+		rows := &staticRows{cols: columnNames(cols), data: data}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+			t.Fatalf("%s: export failed: %v", format, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q (err: %v)", format, got, want, err)
+		}
+	}
+}
+
+func TestWriteRows_BadUUIDIsRowError(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "UNIQUEIDENTIFIER"}}
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{guidBytes}, {[]byte{1, 2, 3}}}}
+	w := &stubWriter{}
+	rejects := filepath.Join(t.TempDir(), "rejects.jsonl")
+	run := exportRun{rowErrs: newRowErrorHandler(RowErrorSkip, rejects)}
+	res, err := runExport(context.Background(), rows, cols, w, run)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if res.RowsWritten != 1 || res.RowsRejected != 1 {
+		t.Errorf("got %d written, %d rejected, want 1 and 1", res.RowsWritten, res.RowsRejected)
+	}
+	got, _ := os.ReadFile(rejects)
+	if !strings.Contains(string(got), `"row":2`) || !strings.Contains(string(got), "column id") {
+		t.Errorf("unexpected reject file %q", got)
+	}
+}
+
+func TestParseBinaryEncoding(t *testing.T) {
+	for in, want := range map[string]BinaryEncoding{"": BinaryAuto, "auto": BinaryAuto, "base64": BinaryBase64, "hex": BinaryHex} {
+		got, err := ParseBinaryEncoding(in)
+		if err != nil || got != want {
+			t.Errorf("ParseBinaryEncoding(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseBinaryEncoding("base32"); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
+
+func TestValueConverter_Binary(t *testing.T) {
+	// Binary values that look like numbers must not be guessed as such
+	in := []byte("123")
+	for _, c := range []struct {
+		conv   valueConverter
+		expect interface{}
+	}{
+		{valueConverter{binary: BinaryBase64}, "MTIz"},
+		{valueConverter{binary: BinaryHex}, "313233"},
+	} {
+		for _, typ := range []string{"BINARY", "VARBINARY", "IMAGE", "TIMESTAMP"} {
+			got, err := c.conv.convert(Column{DatabaseType: typ}, in)
+			if err != nil || got != c.expect {
+				t.Errorf("%s with %s: got %#v, want %#v (err: %v)", typ, c.conv.binary, got, c.expect, err)
+			}
+		}
+	}
+	got, _ := valueConverter{}.convert(Column{DatabaseType: "VARBINARY"}, in)
+	if b, ok := got.([]byte); !ok || string(b) != "123" {
+		t.Errorf("auto: got %#v, want the bytes", got)
+	}
+}
+
+func TestFileWriter_Binary(t *testing.T) {
+	cols := []Column{{Name: "data", DatabaseType: "VARBINARY", Nullable: true}}
+	data := [][]interface{}{{[]byte{0x00, 0xff, '1'}}, {nil}}
+	cases := []struct {
+		format   string
+		encoding BinaryEncoding
+		want     string
+	}{
+		{"json", BinaryAuto, `[{"data":"AP8x"},{"data":null}]`},
+		{"csv", BinaryAuto, "data\n00ff31\n\n"},
+		{"tsv", BinaryAuto, "data\n00ff31\n\\N\n"},
+		{"json", BinaryHex, `[{"data":"00ff31"},{"data":null}]`},
+		{"csv", BinaryBase64, "data\nAP8x\n\n"},
+	}
+	for _, c := range cases {
+		opts := WriterOptions{Table: "t", Output: filepath.Join(t.TempDir(), "out."+c.format), BinaryEncoding: c.encoding}
+		w := newFileWriter(opts, c.format)
+		rows := &staticRows{cols: columnNames(cols), data: data}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{conv: newValueConverter(opts)}); err != nil {
+			t.Fatalf("%s/%s: export failed: %v", c.format, c.encoding, err)
+		}
+		got, err := os.ReadFile(opts.Output)
+		if err != nil || string(got) != c.want {
+			t.Errorf("%s/%s: got %q, want %q (err: %v)", c.format, c.encoding, got, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package dbexport
+
+import (
+	"getmssql/i18n"
+	"time"
+)
+
+// OverwritePolicy controls what a writer does when its output target
+// (a file, or a table in a database file) already exists.
+type OverwritePolicy string
+
+const (
+	// OverwriteDefault asks before dropping an existing database table and
+	// replaces existing files, which is how the CLI has always behaved.
+	OverwriteDefault OverwritePolicy = ""
+	// OverwritePrompt asks on the terminal before replacing any target.
+	OverwritePrompt OverwritePolicy = "prompt"
+	// OverwriteAlways replaces existing targets without asking.
+	OverwriteAlways OverwritePolicy = "always"
+	// OverwriteNever fails with ErrTargetExists if the target exists.
+	OverwriteNever OverwritePolicy = "never"
+)
+
+// ParseOverwritePolicy validates a policy name as given on the command line.
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	switch p := OverwritePolicy(s); p {
+	case OverwriteDefault, OverwritePrompt, OverwriteAlways, OverwriteNever:
+		return p, nil
+	}
+	return "", i18n.Errorf("overwrite.invalid", s)
+}
+
+// TypeMode controls how the database writers declare their columns.
+type TypeMode string
+
+const (
+	// TypesNative maps each source column type to the closest type of the
+	// target database. It is the default.
+	TypesNative TypeMode = "native"
+	// TypesText declares every column as TEXT, as older versions did.
+	TypesText TypeMode = "text"
+)
+
+// ParseTypeMode validates a type mode as given on the command line.
+// The empty string selects TypesNative.
+func ParseTypeMode(s string) (TypeMode, error) {
+	switch m := TypeMode(s); m {
+	case "":
+		return TypesNative, nil
+	case TypesNative, TypesText:
+		return m, nil
+	}
+	return "", i18n.Errorf("types.invalid_mode", s)
+}
+
+// BinaryEncoding selects how binary column values are written as text.
+type BinaryEncoding string
+
+const (
+	// BinaryAuto writes binary values as base64 in JSON and as hex elsewhere.
+	// It is the default.
+	BinaryAuto BinaryEncoding = "auto"
+	// BinaryBase64 writes binary values in standard base64.
+	BinaryBase64 BinaryEncoding = "base64"
+	// BinaryHex writes binary values as lowercase hex digits.
+	BinaryHex BinaryEncoding = "hex"
+)
+
+// ParseBinaryEncoding validates a binary encoding as given on the command
+// line. The empty string selects BinaryAuto.
+func ParseBinaryEncoding(s string) (BinaryEncoding, error) {
+	switch e := BinaryEncoding(s); e {
+	case "":
+		return BinaryAuto, nil
+	case BinaryAuto, BinaryBase64, BinaryHex:
+		return e, nil
+	}
+	return "", i18n.Errorf("binary.invalid_encoding", s)
+}
+
+// RowErrorPolicy controls what happens to a row that cannot be read.
+type RowErrorPolicy string
+
+const (
+	// RowErrorFail stops the export at the first failed row. It is the default.
+	RowErrorFail RowErrorPolicy = "fail"
+	// RowErrorSkip leaves failed rows out and carries on.
+	RowErrorSkip RowErrorPolicy = "skip"
+	// RowErrorLog is like RowErrorSkip but also reports each row on stderr.
+	RowErrorLog RowErrorPolicy = "log"
+)
+
+// ParseRowErrorPolicy validates a policy name as given on the command line.
+// The empty string selects RowErrorFail.
+func ParseRowErrorPolicy(s string) (RowErrorPolicy, error) {
+	switch p := RowErrorPolicy(s); p {
+	case "":
+		return RowErrorFail, nil
+	case RowErrorFail, RowErrorSkip, RowErrorLog:
+		return p, nil
+	}
+	return "", i18n.Errorf("rows.invalid_policy", s)
+}
+
+// ExportOptions configures DownloadTable.
+type ExportOptions struct {
+	// Format is the registered output format name. It defaults to "json".
+	Format string
+	// Writer, when set, is used instead of looking up Format in the registry.
+	Writer Writer
+	// Fields lists the columns to export. When empty, FieldsFile is read,
+	// and when both are empty all columns are exported.
+	Fields []string
+	// FieldsFile names a file listing the columns to export, one per line.
+	FieldsFile string
+	// ColumnTypes overrides the exported type of the named columns. When
+	// empty, TypesFile is read, if set; see ReadTypesFile.
+	ColumnTypes map[string]ColumnType
+	// TypesFile names a YAML or JSON file with column type overrides.
+	TypesFile string
+	// Where is an optional SQL filter, without the WHERE keyword, applied to
+	// both the row count and the exported rows.
+	Where string
+	// BatchSize is the number of rows handed to the writer at a time
+	// (and committed per transaction by the database writers). It defaults to 10000.
+	BatchSize int
+	// Progress receives progress updates. It defaults to NoProgress.
+	Progress ProgressReporter
+	// OnRowError decides what happens to rows that cannot be read.
+	// It defaults to RowErrorFail.
+	OnRowError RowErrorPolicy
+	// RejectFile, when set, receives one JSON line with the row number and
+	// the reason for every failed row. It is only created if a row fails.
+	RejectFile string
+
+	// WriterOptions holds the output settings passed to the writer.
+	// Its Table field is filled in from the table being exported.
+	WriterOptions
+}
+
+// ExportResult describes a finished export.
+type ExportResult struct {
+	Table  string
+	Format string
+	// Output is where the data went, as reported by the writer (see Locator).
+	Output string
+	// Columns is the resolved column schema of the exported result set.
+	Columns     []Column
+	RowsWritten int64
+	// RowsRejected counts the rows that failed and were skipped or, with
+	// RowErrorFail, ended the export.
+	RowsRejected int64
+	// RejectFile is the reject file that was written, or empty if none was.
+	RejectFile string
+	// BytesWritten is the size of the output as reported by the writer
+	// (see ByteCounter), or 0 if the writer does not report it.
+	BytesWritten int64
+	Duration     time.Duration
+	// Aborted is true when the writer declined to start, for example because
+	// the user answered no at an overwrite prompt. Nothing was written.
+	Aborted bool
+}
@@ -0,0 +1,131 @@
+package dbexport
+
+import (
+	"context"
+	"fmt"
+	"getmssql/i18n"
+	"os"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+func init() {
+	RegisterFormat("arrow", func(opts WriterOptions) Writer {
+		return newArrowWriter(opts)
+	})
+}
+
+// ArrowOptions configures the arrow format.
+type ArrowOptions struct {
+	// Stream writes the Arrow IPC streaming format instead of the file
+	// (Feather v2) format. A stream has no footer, so readers can consume it
+	// as it is written, but cannot seek to a record batch.
+	Stream bool
+}
+
+// arrowIPCWriter is implemented by ipc.Writer and ipc.FileWriter.
+type arrowIPCWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// arrowWriter writes table data as Arrow IPC, one record batch per batch of
+// rows, typed as in the parquet format (see arrowType). By default the file
+// is named after the table, with the .arrow extension for files and .arrows
+// for streams.
+type arrowWriter struct {
+	opts      WriterOptions
+	filename  string
+	overwrite OverwritePolicy
+	scanln    func(...interface{}) (int, error)
+	file      *os.File
+	out       *countingWriter
+	enc       *arrowEncoder
+	ipc       arrowIPCWriter
+}
+
+func newArrowWriter(opts WriterOptions) *arrowWriter {
+	filename := opts.Output
+	if filename == "" {
+		ext := "arrow"
+		if opts.Arrow.Stream {
+			ext = "arrows"
+		}
+		filename = fmt.Sprintf("%s.%s", strings.ToLower(opts.Table), ext)
+	}
+	return &arrowWriter{opts: opts, filename: filename, overwrite: opts.Overwrite, scanln: scanln}
+}
+
+// Open creates the output file and writes the schema.
+func (w *arrowWriter) Open(ctx context.Context, cols []Column) error {
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.out = &countingWriter{w: file}
+	w.enc = newArrowEncoder(cols, w.opts)
+	if w.opts.Arrow.Stream {
+		w.ipc = ipc.NewWriter(w.out, ipc.WithSchema(w.enc.schema))
+		return nil
+	}
+	if w.ipc, err = ipc.NewFileWriter(w.out, ipc.WithSchema(w.enc.schema)); err != nil {
+		w.enc.release()
+		file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// prepareRow converts a row of driver values for its Arrow column builders.
+func (w *arrowWriter) prepareRow(row []interface{}) error {
+	return w.enc.prepareRow(row)
+}
+
+// WriteBatch writes the rows as one record batch.
+func (w *arrowWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	rec := w.enc.record(rows)
+	defer rec.Release()
+	if err := w.ipc.Write(rec); err != nil {
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// NativeValues reports that the writer takes driver values and converts them
+// itself.
+func (w *arrowWriter) NativeValues() bool {
+	return true
+}
+
+// storesWKB reports that geography and geometry values are stored as WKB
+// binary columns, unless every column is text.
+func (w *arrowWriter) storesWKB() bool {
+	return w.opts.Types != TypesText
+}
+
+// Close writes the end of the stream or the file footer and closes the file.
+func (w *arrowWriter) Close() error {
+	err := w.ipc.Close()
+	w.enc.release()
+	if err != nil {
+		w.file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.file.Close()
+}
+
+// Location returns the output file name.
+func (w *arrowWriter) Location() string {
+	return w.filename
+}
+
+// BytesWritten returns the number of bytes written to the file.
+func (w *arrowWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
@@ -0,0 +1,85 @@
+package dbexport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+func TestArrowWriter(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		dir := t.TempDir()
+		w := newArrowWriter(WriterOptions{Table: "T", Arrow: ArrowOptions{Stream: stream}})
+		w.filename = filepath.Join(dir, w.filename)
+		rows := &staticRows{cols: columnNames(typedColumns), data: typedRows()}
+		res, err := runExport(context.Background(), rows, typedColumns, w, exportRun{batchSize: 4})
+		if err != nil {
+			t.Fatalf("stream=%v: export failed: %v", stream, err)
+		}
+		wantName := "t.arrow"
+		if stream {
+			wantName = "t.arrows"
+		}
+		if filepath.Base(res.Output) != wantName {
+			t.Errorf("stream=%v: file %s, want %s", stream, filepath.Base(res.Output), wantName)
+		}
+
+		f, err := os.Open(res.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema *arrow.Schema
+		var records []arrow.Record
+		if stream {
+			r, err := ipc.NewReader(f)
+			if err != nil {
+				t.Fatalf("not an Arrow stream: %v", err)
+			}
+			schema = r.Schema()
+			for r.Next() {
+				rec := r.Record()
+				rec.Retain()
+				records = append(records, rec)
+			}
+			r.Release()
+		} else {
+			r, err := ipc.NewFileReader(f)
+			if err != nil {
+				t.Fatalf("not an Arrow file: %v", err)
+			}
+			schema = r.Schema()
+			for i := 0; i < r.NumRecords(); i++ {
+				rec, err := r.Record(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rec.Retain()
+				records = append(records, rec)
+			}
+			r.Close()
+		}
+		f.Close()
+
+		if !schema.Equal(arrowSchema(typedColumns, TypesNative)) {
+			t.Errorf("stream=%v: schema %s", stream, schema)
+		}
+		// One record batch per WriteBatch
+		if len(records) != 2 || records[0].NumRows() != 4 || records[1].NumRows() != 2 {
+			t.Fatalf("stream=%v: got %d record batches", stream, len(records))
+		}
+		if got := records[0].Column(2).(*array.Decimal128).Value(0).ToString(2); got != "12.34" {
+			t.Errorf("stream=%v: price %s, want 12.34", stream, got)
+		}
+		if got := records[1].Column(9).(*array.Binary).Value(0); len(got) != 2 || got[1] != 5 {
+			t.Errorf("stream=%v: data %v", stream, got)
+		}
+		for _, rec := range records {
+			rec.Release()
+		}
+	}
+}
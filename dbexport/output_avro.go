@@ -0,0 +1,435 @@
+package dbexport
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"getmssql/i18n"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/avro/v2/ocf"
+)
+
+func init() {
+	RegisterFormat("avro", func(opts WriterOptions) Writer {
+		return newAvroWriter(opts)
+	})
+}
+
+// AvroCodec is the compression codec of Avro file blocks.
+type AvroCodec string
+
+const (
+	// AvroDeflate compresses with deflate. It is the default.
+	AvroDeflate AvroCodec = "deflate"
+	// AvroSnappy compresses with Snappy.
+	AvroSnappy AvroCodec = "snappy"
+	// AvroZstd compresses with Zstandard.
+	AvroZstd AvroCodec = "zstd"
+	// AvroNone writes uncompressed blocks.
+	AvroNone AvroCodec = "none"
+)
+
+var avroCodecs = map[AvroCodec]ocf.CodecName{
+	AvroDeflate: ocf.Deflate,
+	AvroSnappy:  ocf.Snappy,
+	AvroZstd:    ocf.ZStandard,
+	AvroNone:    ocf.Null,
+}
+
+// ParseAvroCodec validates a compression codec as given on the command line.
+// The empty string selects AvroDeflate.
+func ParseAvroCodec(s string) (AvroCodec, error) {
+	c := AvroCodec(strings.ToLower(s))
+	if c == "" {
+		return AvroDeflate, nil
+	}
+	if _, ok := avroCodecs[c]; !ok {
+		return "", i18n.Errorf("avro.invalid_codec", s)
+	}
+	return c, nil
+}
+
+// AvroOptions configures the avro format.
+type AvroOptions struct {
+	// Codec compresses the file blocks. The zero value behaves like
+	// AvroDeflate.
+	Codec AvroCodec
+}
+
+// Avro value kinds of avroField.
+const (
+	avroBoolean        = "boolean"
+	avroInt            = "int"
+	avroLong           = "long"
+	avroFloat          = "float"
+	avroDouble         = "double"
+	avroBytes          = "bytes"
+	avroString         = "string"
+	avroDecimal        = "decimal"
+	avroDate           = "date"
+	avroTime           = "time-micros"
+	avroTimestamp      = "timestamp-micros"
+	avroLocalTimestamp = "local-timestamp-micros"
+	avroUUID           = "uuid"
+)
+
+// avroField is a field of the record schema of an export.
+type avroField struct {
+	name     string
+	kind     string
+	nullable bool
+	scale    int64 // of decimals
+}
+
+// avroKind returns the Avro type, or logical type, holding the values of col.
+// With TypesText every column is a string.
+func avroKind(col Column, mode TypeMode) string {
+	if mode == TypesText {
+		return avroString
+	}
+	switch typ := strings.ToUpper(col.DatabaseType); {
+	case typ == "BIT":
+		return avroBoolean
+	case typ == "TINYINT", typ == "SMALLINT", typ == "INT":
+		return avroInt
+	case typ == "BIGINT":
+		return avroLong
+	case typ == "REAL":
+		return avroFloat
+	case typ == "FLOAT":
+		return avroDouble
+	case isDecimalType(typ):
+		if p := decimalPrecision(col); p > 0 && p <= 38 {
+			return avroDecimal
+		}
+	case typ == "DATE":
+		return avroDate
+	case typ == "TIME":
+		return avroTime
+	case typ == "DATETIME", typ == "DATETIME2", typ == "SMALLDATETIME":
+		return avroLocalTimestamp
+	case typ == "DATETIMEOFFSET":
+		return avroTimestamp
+	case typ == "UNIQUEIDENTIFIER":
+		return avroUUID
+	case isBinaryType(typ), isSpatialType(typ):
+		return avroBytes
+	}
+	return avroString
+}
+
+// avroSchema returns the record schema of an export of table, as JSON, and
+// its fields. Nullable columns are unions with null, which is their default.
+// Table and column names are changed to valid Avro names where needed.
+func avroSchema(table string, cols []Column, mode TypeMode) (string, []avroField, error) {
+	fields := make([]avroField, len(cols))
+	jsonFields := make([]map[string]interface{}, len(cols))
+	seen := make(map[string]bool)
+	for i, col := range cols {
+		f := avroField{name: avroName(col.Name), kind: avroKind(col, mode), nullable: col.Nullable}
+		for n := 2; seen[f.name]; n++ {
+			f.name = fmt.Sprintf("%s_%d", avroName(col.Name), n)
+		}
+		seen[f.name] = true
+		var typ interface{} = f.kind
+		switch f.kind {
+		case avroDecimal:
+			f.scale = decimalScale(col)
+			typ = map[string]interface{}{"type": avroBytes, "logicalType": avroDecimal, "precision": decimalPrecision(col), "scale": f.scale}
+		case avroDate:
+			typ = map[string]interface{}{"type": avroInt, "logicalType": avroDate}
+		case avroTime, avroTimestamp, avroLocalTimestamp:
+			typ = map[string]interface{}{"type": avroLong, "logicalType": f.kind}
+		case avroUUID:
+			typ = map[string]interface{}{"type": avroString, "logicalType": avroUUID}
+		}
+		jsonFields[i] = map[string]interface{}{"name": f.name, "type": typ}
+		if f.nullable {
+			jsonFields[i]["type"] = []interface{}{"null", typ}
+			jsonFields[i]["default"] = nil
+		}
+		fields[i] = f
+	}
+	schema, err := json.Marshal(map[string]interface{}{"type": "record", "name": avroName(table), "fields": jsonFields})
+	return string(schema), fields, err
+}
+
+// avroName returns s with each character Avro names do not allow replaced by
+// an underscore.
+func avroName(s string) string {
+	var name strings.Builder
+	for _, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			c = '_'
+		}
+		name.WriteRune(c)
+	}
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return "_" + name.String()
+	}
+	return name.String()
+}
+
+// avroWriter writes table data to an Avro object container file with a
+// record schema derived from the columns (see avroKind), one block per batch
+// of rows. By default the file is named after the table.
+type avroWriter struct {
+	opts      WriterOptions
+	filename  string
+	overwrite OverwritePolicy
+	scanln    func(...interface{}) (int, error)
+	conv      valueConverter // string fields
+	file      *os.File
+	out       *countingWriter
+	enc       *ocf.Encoder
+	cols      []Column
+	fields    []avroField
+	rec       []byte
+}
+
+func newAvroWriter(opts WriterOptions) *avroWriter {
+	filename := opts.Output
+	if filename == "" {
+		filename = fmt.Sprintf("%s.avro", strings.ToLower(opts.Table))
+	}
+	return &avroWriter{opts: opts, filename: filename, overwrite: opts.Overwrite, scanln: scanln, conv: newTextConverter(opts)}
+}
+
+// Open creates the output file and writes the header with the schema.
+func (w *avroWriter) Open(ctx context.Context, cols []Column) error {
+	codec, err := ParseAvroCodec(string(w.opts.Avro.Codec))
+	if err != nil {
+		return err
+	}
+	schema, fields, err := avroSchema(w.opts.Table, cols, w.opts.Types)
+	if err != nil {
+		return err
+	}
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.out = &countingWriter{w: file}
+	w.cols = cols
+	w.fields = fields
+	// Blocks are written by WriteBatch
+	w.enc, err = ocf.NewEncoder(schema, w.out, ocf.WithCodec(avroCodecs[codec]), ocf.WithBlockLength(math.MaxInt32))
+	if err != nil {
+		file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// prepareRow converts a row of driver values to the Go values appendAvro
+// encodes, see avroValue.
+func (w *avroWriter) prepareRow(row []interface{}) error {
+	for i, v := range row {
+		val, err := w.avroValue(w.fields[i], w.cols[i], v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", w.cols[i].Name, err)
+		}
+		row[i] = val
+	}
+	return nil
+}
+
+// avroValue converts a driver value to the value of field f: bool, int32,
+// int64, float32, float64, []byte or string. Dates are days and times and
+// timestamps microseconds, from midnight or the Unix epoch; local timestamps
+// count the wall clock of datetime values from the epoch as if it were UTC.
+// Decimals become their unscaled two's-complement bytes.
+func (w *avroWriter) avroValue(f avroField, col Column, v interface{}) (interface{}, error) {
+	if v == nil {
+		if !f.nullable {
+			return nil, i18n.Errorf("avro.null_value")
+		}
+		return nil, nil
+	}
+	switch f.kind {
+	case avroBoolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case avroInt:
+		if n, ok := v.(int64); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int32(n), nil
+		}
+	case avroLong:
+		if n, ok := v.(int64); ok {
+			return n, nil
+		}
+	case avroFloat:
+		switch x := v.(type) {
+		case float32:
+			return x, nil
+		case float64:
+			return float32(x), nil
+		}
+	case avroDouble:
+		switch x := v.(type) {
+		case float32:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		}
+	case avroDecimal:
+		if b, ok := v.([]byte); ok {
+			return avroDecimalBytes(string(b), f.scale)
+		}
+	case avroDate:
+		if t, ok := v.(time.Time); ok {
+			return int32(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400), nil
+		}
+	case avroTime:
+		if t, ok := v.(time.Time); ok {
+			midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			return int64(t.Sub(midnight) / time.Microsecond), nil
+		}
+	case avroTimestamp:
+		if t, ok := v.(time.Time); ok {
+			return t.Unix()*1e6 + int64(t.Nanosecond()/1e3), nil
+		}
+	case avroLocalTimestamp:
+		if t, ok := v.(time.Time); ok {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			return t.Unix()*1e6 + int64(t.Nanosecond()/1e3), nil
+		}
+	case avroUUID:
+		if b, ok := v.([]byte); ok {
+			return mssqlUUID(b)
+		}
+	case avroBytes:
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case string:
+			return []byte(x), nil
+		}
+	case avroString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		text, err := w.conv.convert(col, v)
+		if err != nil {
+			return nil, err
+		}
+		return formatDelimitedValues([]interface{}{text}, "")[0], nil
+	}
+	return nil, i18n.Errorf("avro.bad_value", v, f.kind)
+}
+
+// avroDecimalBytes returns decimal text as the big-endian two's-complement
+// bytes of its unscaled value at scale.
+func avroDecimalBytes(s string, scale int64) ([]byte, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, i18n.Errorf("avro.bad_value", s, avroDecimal)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)))
+	if !r.IsInt() {
+		return nil, i18n.Errorf("avro.bad_value", s, avroDecimal+"(scale "+strconv.FormatInt(scale, 10)+")")
+	}
+	n := r.Num()
+	if n.Sign() >= 0 {
+		b := n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b, nil
+	}
+	// Two's complement of a negative number: 2^(8*size) + n
+	size := uint(n.BitLen()/8+1) * 8
+	return new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), size), n).Bytes(), nil
+}
+
+// appendAvro appends the binary encoding of a prepared value of field f.
+// Nullable fields are unions whose first branch is null.
+func appendAvro(b []byte, f avroField, v interface{}) []byte {
+	if f.nullable {
+		if v == nil {
+			return binary.AppendVarint(b, 0)
+		}
+		b = binary.AppendVarint(b, 1)
+	}
+	// Avro ints and longs are zig-zag varints, as binary.AppendVarint writes them
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return append(b, 1)
+		}
+		return append(b, 0)
+	case int32:
+		return binary.AppendVarint(b, int64(x))
+	case int64:
+		return binary.AppendVarint(b, x)
+	case float32:
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	case float64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(x))
+	case []byte:
+		return append(binary.AppendVarint(b, int64(len(x))), x...)
+	case string:
+		return append(binary.AppendVarint(b, int64(len(x))), x...)
+	}
+	return b
+}
+
+// WriteBatch encodes the rows and writes them as one block.
+func (w *avroWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	for _, vals := range rows {
+		w.rec = w.rec[:0]
+		for i, v := range vals {
+			w.rec = appendAvro(w.rec, w.fields[i], v)
+		}
+		if _, err := w.enc.Write(w.rec); err != nil {
+			return i18n.Errorf("file.write_error", err)
+		}
+	}
+	if err := w.enc.Flush(); err != nil {
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// NativeValues reports that the writer takes driver values and converts them
+// itself.
+func (w *avroWriter) NativeValues() bool {
+	return true
+}
+
+// storesWKB reports that geography and geometry values are stored as WKB
+// bytes, unless every column is a string.
+func (w *avroWriter) storesWKB() bool {
+	return w.opts.Types != TypesText
+}
+
+// Close writes any pending block and closes the file.
+func (w *avroWriter) Close() error {
+	if err := w.enc.Close(); err != nil {
+		w.file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.file.Close()
+}
+
+// Location returns the output file name.
+func (w *avroWriter) Location() string {
+	return w.filename
+}
+
+// BytesWritten returns the number of bytes written to the file.
+func (w *avroWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
@@ -0,0 +1,169 @@
+package dbexport
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2/ocf"
+)
+
+func TestAvroWriter(t *testing.T) {
+	for _, codec := range []AvroCodec{"", AvroSnappy, AvroZstd, AvroNone} {
+		dir := t.TempDir()
+		w := newAvroWriter(WriterOptions{Table: "T", Avro: AvroOptions{Codec: codec}})
+		w.filename = filepath.Join(dir, w.filename)
+		rows := &staticRows{cols: columnNames(typedColumns), data: typedRows()}
+		res, err := runExport(context.Background(), rows, typedColumns, w, exportRun{batchSize: 4})
+		if err != nil {
+			t.Fatalf("codec %q: export failed: %v", codec, err)
+		}
+		if filepath.Base(res.Output) != "t.avro" {
+			t.Errorf("file %s, want t.avro", filepath.Base(res.Output))
+		}
+
+		f, err := os.Open(res.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := ocf.NewDecoder(f)
+		if err != nil {
+			t.Fatalf("codec %q: not an Avro file: %v", codec, err)
+		}
+		wantCodec := string(avroCodecs[codec])
+		if codec == "" {
+			wantCodec = "deflate"
+		}
+		if got := string(dec.Metadata()["avro.codec"]); got != wantCodec {
+			t.Errorf("codec %q: file codec %s, want %s", codec, got, wantCodec)
+		}
+		var records []map[string]interface{}
+		for dec.HasNext() {
+			var rec map[string]interface{}
+			if err := dec.Decode(&rec); err != nil {
+				t.Fatalf("codec %q: record %d: %v", codec, len(records), err)
+			}
+			records = append(records, rec)
+		}
+		f.Close()
+		if err := dec.Error(); err != nil || len(records) != 6 {
+			t.Fatalf("codec %q: read %d records (%v)", codec, len(records), err)
+		}
+
+		first := records[0]
+		at := time.Date(2024, 2, 29, 13, 45, 10, 123456000, time.UTC)
+		if first["id"] != 1 || first["tiny"] != 50 || first["ok"] != false || first["ratio"] != 0.5 || first["name"] != "ñandú" {
+			t.Errorf("codec %q: record %v", codec, first)
+		}
+		if price, ok := first["price"].(*big.Rat); !ok || price.FloatString(2) != "12.34" {
+			t.Errorf("codec %q: price %v, want 12.34", codec, first["price"])
+		}
+		if born, ok := first["born"].(time.Time); !ok || !born.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("codec %q: born %v", codec, first["born"])
+		}
+		// datetime2 is a local timestamp that keeps its wall clock; the
+		// decoder returns it in a map keyed by the branch of the union
+		localAt := first["at"]
+		if branch, ok := localAt.(map[string]interface{}); ok {
+			localAt = branch["long.local-timestamp-micros"]
+		}
+		if got, ok := localAt.(time.Time); !ok || got.Format("2006-01-02 15:04:05.000000") != "2024-02-29 13:45:10.123456" {
+			t.Errorf("codec %q: at %v, want %v", codec, first["at"], at)
+		}
+		// datetimeoffset keeps the instant
+		if got, ok := first["off"].(time.Time); !ok || !got.Equal(at.Add(-123456000)) {
+			t.Errorf("codec %q: off %v", codec, first["off"])
+		}
+		if first["guid"] != "6f9619ff-8b86-d011-b42d-00c04fc964ff" || !bytes.Equal(first["data"].([]byte), []byte{0, 1}) {
+			t.Errorf("codec %q: guid %v, data %v", codec, first["guid"], first["data"])
+		}
+		for name, v := range records[5] {
+			if name != "id" && v != nil {
+				t.Errorf("codec %q: NULL %s read as %v", codec, name, v)
+			}
+		}
+	}
+}
+
+func TestAvroSchema(t *testing.T) {
+	cols := []Column{
+		{Name: "id", DatabaseType: "BIGINT"},
+		{Name: "1st name", DatabaseType: "NVARCHAR", Nullable: true},
+		{Name: "1st-name", DatabaseType: "TIME", Nullable: true},
+		{Name: "amount", DatabaseType: "MONEY"},
+		{Name: "at", DatabaseType: "DATETIME2"},
+		{Name: "off", DatabaseType: "DATETIMEOFFSET"},
+	}
+	schema, fields, err := avroSchema("dbo.orders", cols, TypesNative)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"name":"dbo_orders"`,
+		`{"name":"id","type":"long"}`,
+		`{"default":null,"name":"_1st_name","type":["null","string"]}`,
+		`{"default":null,"name":"_1st_name_2","type":["null",{"logicalType":"time-micros","type":"long"}]}`,
+		`{"name":"amount","type":{"logicalType":"decimal","precision":19,"scale":4,"type":"bytes"}}`,
+		`{"name":"at","type":{"logicalType":"local-timestamp-micros","type":"long"}}`,
+		`{"name":"off","type":{"logicalType":"timestamp-micros","type":"long"}}`,
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema lacks %s:\n%s", want, schema)
+		}
+	}
+	for in, want := range map[string]string{"ñandú": "_and_", "año 2024": "a_o_2024", "9": "_9", "": "_"} {
+		if got := avroName(in); got != want {
+			t.Errorf("avroName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if fields[3].scale != 4 {
+		t.Errorf("amount scale %d, want 4", fields[3].scale)
+	}
+	if schema, _, _ = avroSchema("t", cols, TypesText); strings.Count(schema, `"string"`) != 6 {
+		t.Errorf("text schema %s", schema)
+	}
+}
+
+func TestAvroDecimalBytes(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		scale int64
+		want  []byte
+	}{
+		{"0", 2, []byte{0}},
+		{"1.27", 2, []byte{0x7f}},
+		{"1.28", 2, []byte{0x00, 0x80}},
+		{"-1.28", 2, []byte{0xff, 0x80}},
+		{"-0.01", 2, []byte{0xff}},
+		{"-327.68", 2, []byte{0xff, 0x80, 0x00}},
+	} {
+		got, err := avroDecimalBytes(tt.in, tt.scale)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s = %x (%v), want %x", tt.in, got, err, tt.want)
+		}
+		// The bytes read back as the same value
+		n := new(big.Int).SetBytes(got)
+		if len(got) > 0 && got[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(got)*8)))
+		}
+		if r := new(big.Rat).SetFrac(n, big.NewInt(100)); r.FloatString(2) != strings.TrimPrefix(tt.in, "+") && tt.in != "0" {
+			t.Errorf("%s read back as %s", tt.in, r.FloatString(2))
+		}
+	}
+	if _, err := avroDecimalBytes("1.234", 2); err == nil {
+		t.Error("expected an error for a value beyond the scale")
+	}
+
+	w := newAvroWriter(WriterOptions{Table: "t"})
+	if _, err := w.avroValue(avroField{kind: avroInt}, Column{Name: "n"}, nil); err == nil {
+		t.Error("expected an error for NULL in a non-nullable field")
+	}
+	if _, err := ParseAvroCodec("lz4"); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
@@ -4,124 +4,244 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"getmssql/i18n"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 )
 
-// WriteDuckDBRows is a wrapper for WriteDuckDB that accepts Rows interface
-func WriteDuckDBRows(rows Rows, cols []string, table string, start time.Time) error {
-	sqlRows, ok := rows.(*sql.Rows)
-	if !ok {
-		return fmt.Errorf("WriteDuckDB requires *sql.Rows")
-	}
-	return WriteDuckDB(sqlRows, cols, table, start)
+func init() {
+	RegisterFormat("duckdb", func(opts WriterOptions) Writer {
+		return newDuckDBWriter(opts, openDuckDB, scanln)
+	})
 }
 
-// WriteDuckDBWithDeps allows dependency injection for testing.
-func WriteDuckDBWithDeps(rows *sql.Rows, cols []string, table string, start time.Time, openDB func(string, string) (*sql.DB, error), scanlnFn func(...interface{}) (int, error)) error {
-	// Set up context that cancels on SIGINT (Ctrl-C)
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-	if rows == nil {
-		return fmt.Errorf("rows is nil")
+// duckDBWriter writes table data to a table in a DuckDB database file,
+// output.duckdb unless WriterOptions.Output says otherwise.
+type duckDBWriter struct {
+	table     string
+	dbFile    string
+	openDB    func(string, string) (*sql.DB, error)
+	scanln    func(...interface{}) (int, error)
+	overwrite OverwritePolicy
+	types     TypeMode
+	conv      valueConverter
+	cols      []Column
+	inserter  batchInserter
+}
+
+func newDuckDBWriter(opts WriterOptions, openDB func(string, string) (*sql.DB, error), scanlnFn func(...interface{}) (int, error)) *duckDBWriter {
+	dbFile := opts.Output
+	if dbFile == "" {
+		dbFile = "output.duckdb"
 	}
-	dbFile := "output.duckdb"
-	tableLower := strings.ToLower(table)
-	duckdb, err := openDB("duckdb", dbFile)
+	return &duckDBWriter{
+		table:     opts.Table,
+		dbFile:    dbFile,
+		overwrite: opts.Overwrite,
+		types:     opts.Types,
+		conv:      newTextConverter(opts),
+		openDB:    openDB,
+		scanln:    scanlnFn,
+	}
+}
+
+// Open creates the target table, asking before dropping an existing one,
+// and starts the first transaction.
+func (w *duckDBWriter) Open(ctx context.Context, cols []Column) error {
+	tableLower := strings.ToLower(w.table)
+	duckdb, err := w.openDB("duckdb", w.dbFile)
 	if err != nil {
-		return fmt.Errorf("error opening DuckDB database: %w", err)
+		return i18n.Errorf("db.open_error", "DuckDB", err)
 	}
-	defer duckdb.Close()
 
 	// Check if table exists
 	var tableExists int
-	err = duckdb.QueryRow(fmt.Sprintf("SELECT count(*) FROM information_schema.tables WHERE table_name='%s'", tableLower)).Scan(&tableExists)
+	err = duckdb.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM information_schema.tables WHERE table_name='%s'", tableLower)).Scan(&tableExists)
 	if err != nil {
-		return fmt.Errorf("error checking if table exists in DuckDB: %w", err)
+		duckdb.Close()
+		return i18n.Errorf("db.exists_check_error", "DuckDB", err)
 	}
 	if tableExists > 0 {
-		fmt.Printf("Table '%s' already exists in %s. Delete and recreate? (y/N): ", tableLower, dbFile)
-		var response string
-		scanlnFn(&response)
-		if strings.ToLower(strings.TrimSpace(response)) == "y" {
-			dropStmt := fmt.Sprintf("DROP TABLE IF EXISTS \"%s\"", tableLower)
-			if _, err := duckdb.Exec(dropStmt); err != nil {
-				return fmt.Errorf("error dropping table in DuckDB: %w", err)
-			}
-			fmt.Printf("Table '%s' dropped.\n", tableLower)
-		} else {
-			fmt.Println("Aborted by user.")
-			return nil
+		exists := i18n.T("db.table_exists", tableLower, w.dbFile)
+		if err := confirmOverwrite(w.overwrite, exists, i18n.T("db.recreate"), w.scanln); err != nil {
+			duckdb.Close()
+			return err
+		}
+		dropStmt := fmt.Sprintf("DROP TABLE IF EXISTS \"%s\"", tableLower)
+		if _, err := duckdb.ExecContext(ctx, dropStmt); err != nil {
+			duckdb.Close()
+			return i18n.Errorf("db.drop_error", "DuckDB", err)
 		}
+		fmt.Println(i18n.T("db.table_dropped", tableLower))
 	}
 
 	// Create table (DuckDB uses double quotes for identifiers)
 	colDefs := make([]string, len(cols))
 	for i, col := range cols {
-		colDefs[i] = fmt.Sprintf("\"%s\" TEXT", col)
+		colDefs[i] = fmt.Sprintf("\"%s\" %s", col.Name, duckdbColumnDef(col, w.types))
 	}
 	createStmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS \"%s\" (%s)", tableLower, strings.Join(colDefs, ", "))
-	if _, err := duckdb.Exec(createStmt); err != nil {
-		return fmt.Errorf("error creating table in DuckDB: %w", err)
+	if _, err := duckdb.ExecContext(ctx, createStmt); err != nil {
+		duckdb.Close()
+		return i18n.Errorf("db.create_error", "DuckDB", err)
 	}
 	quotedCols := make([]string, len(cols))
 	for i, col := range cols {
-		quotedCols[i] = fmt.Sprintf("\"%s\"", col)
+		quotedCols[i] = fmt.Sprintf("\"%s\"", col.Name)
 	}
 	insertStmt := fmt.Sprintf("INSERT INTO \"%s\" (%s) VALUES (%s)", tableLower, strings.Join(quotedCols, ", "), strings.TrimRight(strings.Repeat("?,", len(cols)), ","))
-	batchSize := 10000
-	rowCount := 0
-	tx, err := duckdb.Begin()
-	if err != nil {
-		return fmt.Errorf("error starting DuckDB transaction: %w", err)
+	w.cols = cols
+	w.inserter = batchInserter{db: duckdb, engine: "DuckDB", query: insertStmt}
+	if err := w.inserter.begin(ctx); err != nil {
+		duckdb.Close()
+		return err
 	}
-	stmt, err := tx.Prepare(insertStmt)
-	if err != nil {
-		return fmt.Errorf("error preparing DuckDB statement: %w", err)
-	}
-	defer stmt.Close()
-	for rows.Next() {
-		select {
-		case <-ctx.Done():
-			fmt.Println("\nAborted by user (Ctrl-C)")
-			return fmt.Errorf("aborted by user (Ctrl-C)")
-		default:
+	return nil
+}
+
+// prepareRow converts a row of driver values for insertion, see duckdbValue.
+// With TypesText every value is converted to its text form.
+func (w *duckDBWriter) prepareRow(row []interface{}) error {
+	if w.types == TypesText {
+		return w.conv.convertRow(w.cols, row)
+	}
+	for i, v := range row {
+		val, err := duckdbValue(w.conv, w.cols[i], v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", w.cols[i].Name, err)
 		}
-		vals := ScanRowValues(rows, cols)
-		if _, err := stmt.Exec(vals...); err != nil {
-			return fmt.Errorf("error inserting row into DuckDB: %w", err)
+		row[i] = val
+	}
+	return nil
+}
+
+// WriteBatch inserts the rows and commits them as one transaction.
+func (w *duckDBWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	return w.inserter.insert(ctx, rows)
+}
+
+// NativeValues reports that the writer takes driver values: it converts them
+// itself, also when every column is TEXT.
+func (w *duckDBWriter) NativeValues() bool {
+	return true
+}
+
+// duckdbColumnDef returns the declared type of col in the CREATE TABLE
+// statement, plus NOT NULL for non-nullable columns. With TypesText every
+// column is plain TEXT.
+func duckdbColumnDef(col Column, mode TypeMode) string {
+	if mode == TypesText {
+		return "TEXT"
+	}
+	def := duckdbType(col)
+	if !col.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// duckdbType maps an MSSQL column to the native DuckDB type holding its values
+// without loss. Types DuckDB has no equivalent for are stored as TEXT.
+func duckdbType(col Column) string {
+	switch strings.ToUpper(col.DatabaseType) {
+	case "TINYINT", "SMALLINT", "INT":
+		return "INTEGER"
+	case "BIGINT":
+		return "BIGINT"
+	case "BIT":
+		return "BOOLEAN"
+	case "REAL":
+		return "REAL"
+	case "FLOAT":
+		return "DOUBLE"
+	case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY":
+		if p := decimalPrecision(col); p > 0 && p <= 38 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", p, decimalScale(col))
+		}
+	case "DATETIME2", "DATETIME", "SMALLDATETIME":
+		return "TIMESTAMP"
+	case "DATETIMEOFFSET":
+		return "TIMESTAMPTZ"
+	case "DATE":
+		return "DATE"
+	case "TIME":
+		return "TIME"
+	case "UNIQUEIDENTIFIER":
+		return "UUID"
+	case "BINARY", "VARBINARY", "IMAGE", "TIMESTAMP", "ROWVERSION":
+		return "BLOB"
+	case "GEOGRAPHY", "GEOMETRY":
+		// WKB, which the spatial extension reads with ST_GeomFromWKB
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// duckdbValue prepares a driver value for a column of type duckdbType(col).
+// DECIMAL and MONEY arrive as their exact decimal text and uniqueidentifier
+// as raw bytes; both are bound as strings for DuckDB to cast. TEXT columns get
+// the values conv produces for the file formats.
+func duckdbValue(conv valueConverter, col Column, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ := duckdbType(col); {
+	case typ == "TEXT":
+		return conv.convert(col, v)
+	case typ == "UUID":
+		if b, ok := v.([]byte); ok {
+			return mssqlUUID(b)
 		}
-		rowCount++
-		if rowCount%batchSize == 0 {
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("error committing DuckDB transaction: %w", err)
-			}
-			tx, err = duckdb.Begin()
-			if err != nil {
-				return fmt.Errorf("error starting DuckDB transaction: %w", err)
-			}
-			stmt, err = tx.Prepare(insertStmt)
-			if err != nil {
-				return fmt.Errorf("error preparing DuckDB statement: %w", err)
-			}
-			fmt.Printf("\rDownloaded %d rows...", rowCount)
-		} else if rowCount%1000 == 0 {
-			fmt.Printf("\rDownloaded %d rows...", rowCount)
+	case strings.HasPrefix(typ, "DECIMAL"):
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
 		}
 	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("row error: %w", err)
+	return v, nil
+}
+
+// storesWKB reports that geography and geometry values are stored as WKB
+// BLOBs, unless every column is TEXT.
+func (w *duckDBWriter) storesWKB() bool {
+	return w.types != TypesText
+}
+
+// Close commits any pending transaction and closes the database.
+func (w *duckDBWriter) Close() error {
+	return w.inserter.close()
+}
+
+// BytesWritten returns the size of the database file.
+func (w *duckDBWriter) BytesWritten() int64 {
+	info, err := os.Stat(w.dbFile)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Location returns the database file and table written to.
+func (w *duckDBWriter) Location() string {
+	return fmt.Sprintf("%s (table: %s)", w.dbFile, w.table)
+}
+
+// WriteDuckDBRows is a wrapper for WriteDuckDB that accepts Rows interface
+func WriteDuckDBRows(rows Rows, cols []string, table string, start time.Time) error {
+	sqlRows, ok := rows.(*sql.Rows)
+	if !ok {
+		return fmt.Errorf("WriteDuckDB requires *sql.Rows")
 	}
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing DuckDB transaction: %w", err)
+	return WriteDuckDB(sqlRows, cols, table, start)
+}
+
+// WriteDuckDBWithDeps allows dependency injection for testing.
+func WriteDuckDBWithDeps(rows *sql.Rows, cols []string, table string, start time.Time, openDB func(string, string) (*sql.DB, error), scanlnFn func(...interface{}) (int, error)) error {
+	if rows == nil {
+		return fmt.Errorf("rows is nil")
 	}
-	fmt.Printf("\rTotal rows downloaded: %d\n", rowCount)
-	elapsed := time.Since(start)
-	fmt.Printf("Table '%s' data written to %s (table: %s) in %s\n", table, dbFile, table, elapsed)
-	return nil
+	w := newDuckDBWriter(WriterOptions{Table: table}, openDB, scanlnFn)
+	return runLegacyExport(rows, cols, table, w, start)
 }
 
 // WriteDuckDB writes table data to a DuckDB database file.
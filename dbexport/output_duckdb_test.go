@@ -1,6 +1,7 @@
 package dbexport
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/marcboeker/go-duckdb"
 )
 
 func TestWriteDuckDBWithDeps_Errors2(t *testing.T) {
@@ -611,3 +613,118 @@ func TestWriteDuckDBRows_RowsErr(t *testing.T) {
 		t.Errorf("expected error from rows.Err, got: %v", err)
 	}
 }
+
+func TestDuckDBColumnDef(t *testing.T) {
+	cases := []struct {
+		col  Column
+		mode TypeMode
+		want string
+	}{
+		{Column{DatabaseType: "INT"}, TypesNative, "INTEGER NOT NULL"},
+		{Column{DatabaseType: "TINYINT", Nullable: true}, TypesNative, "INTEGER"},
+		{Column{DatabaseType: "BIGINT", Nullable: true}, TypesNative, "BIGINT"},
+		{Column{DatabaseType: "DECIMAL", Precision: 12, Scale: 3, Nullable: true}, TypesNative, "DECIMAL(12,3)"},
+		{Column{DatabaseType: "DECIMAL", Nullable: true}, TypesNative, "TEXT"},
+		{Column{DatabaseType: "MONEY", Nullable: true}, TypesNative, "DECIMAL(19,4)"},
+		{Column{DatabaseType: "DATETIME2", Nullable: true}, TypesNative, "TIMESTAMP"},
+		{Column{DatabaseType: "DATETIMEOFFSET", Nullable: true}, TypesNative, "TIMESTAMPTZ"},
+		{Column{DatabaseType: "DATE", Nullable: true}, TypesNative, "DATE"},
+		{Column{DatabaseType: "BIT", Nullable: true}, TypesNative, "BOOLEAN"},
+		{Column{DatabaseType: "UNIQUEIDENTIFIER", Nullable: true}, TypesNative, "UUID"},
+		{Column{DatabaseType: "VARBINARY", Nullable: true}, TypesNative, "BLOB"},
+		{Column{DatabaseType: "NVARCHAR", Nullable: true}, TypesNative, "TEXT"},
+		{Column{DatabaseType: "INT"}, TypesText, "TEXT"},
+	}
+	for _, c := range cases {
+		if got := duckdbColumnDef(c.col, c.mode); got != c.want {
+			t.Errorf("duckdbColumnDef(%+v, %q) = %q, want %q", c.col, c.mode, got, c.want)
+		}
+	}
+}
+
+func TestDuckDBWriter_NativeTypes(t *testing.T) {
+	dbFile := t.TempDir() + "/typed.duckdb"
+	w := newDuckDBWriter(WriterOptions{Table: "Events", Output: dbFile}, openDuckDB, scanln)
+	cols := []Column{
+		{Name: "id", DatabaseType: "BIGINT"},
+		{Name: "amount", DatabaseType: "DECIMAL", Precision: 20, Scale: 6, Nullable: true},
+		{Name: "at", DatabaseType: "DATETIME2", Nullable: true},
+		{Name: "at_tz", DatabaseType: "DATETIMEOFFSET", Nullable: true},
+		{Name: "day", DatabaseType: "DATE", Nullable: true},
+		{Name: "active", DatabaseType: "BIT", Nullable: true},
+		{Name: "guid", DatabaseType: "UNIQUEIDENTIFIER", Nullable: true},
+		{Name: "payload", DatabaseType: "VARBINARY", Nullable: true},
+	}
+	at := time.Date(2024, 2, 29, 13, 45, 10, 123456000, time.UTC)
+	atTZ := time.Date(2024, 2, 29, 13, 45, 10, 0, time.FixedZone("", -5*3600))
+	// 6F9619FF-8B86-D011-B42D-00C04FC964FF as sent by SQL Server
+	guid := []byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff}
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{
+		{int64(9007199254740993), []byte("12345678901234.123456"), at, atTZ, at, true, guid, []byte{0, 1, 2}},
+		{int64(2), nil, nil, nil, nil, nil, nil, nil},
+	}}
+	res, err := runExport(context.Background(), rows, cols, w, exportRun{})
+	if err != nil || res.RowsWritten != 2 {
+		t.Fatalf("export failed: %v (%+v)", err, res)
+	}
+
+	db, err := sql.Open("duckdb", dbFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var types string
+	err = db.QueryRow(`SELECT string_agg(data_type, ',' ORDER BY ordinal_position) FROM information_schema.columns WHERE table_name = 'events'`).Scan(&types)
+	if err != nil || types != "BIGINT,DECIMAL(20,6),TIMESTAMP,TIMESTAMP WITH TIME ZONE,DATE,BOOLEAN,UUID,BLOB" {
+		t.Errorf("unexpected column types %q (err: %v)", types, err)
+	}
+	var id int64
+	var amount, atStr, atTZStr, day, guidStr string
+	var active bool
+	var payload []byte
+	err = db.QueryRow(`SELECT id, amount::VARCHAR, strftime(at, '%Y-%m-%d %H:%M:%S.%f'), epoch(at_tz::TIMESTAMP)::BIGINT::VARCHAR, day::VARCHAR, active, guid::VARCHAR, payload FROM events WHERE id <> 2`).
+		Scan(&id, &amount, &atStr, &atTZStr, &day, &active, &guidStr, &payload)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if id != 9007199254740993 || amount != "12345678901234.123456" || atStr != "2024-02-29 13:45:10.123456" ||
+		atTZStr != fmt.Sprint(atTZ.Unix()) || day != "2024-02-29" || !active ||
+		guidStr != "6f9619ff-8b86-d011-b42d-00c04fc964ff" || string(payload) != "\x00\x01\x02" {
+		t.Errorf("unexpected values: %d %s %s %s %s %v %s %v", id, amount, atStr, atTZStr, day, active, guidStr, payload)
+	}
+	var nulls int
+	if err := db.QueryRow(`SELECT count(*) FROM events WHERE id = 2 AND amount IS NULL AND guid IS NULL AND payload IS NULL`).Scan(&nulls); err != nil || nulls != 1 {
+		t.Errorf("expected NULLs to round-trip, got %d (err: %v)", nulls, err)
+	}
+}
+
+func TestMSSQLUUID(t *testing.T) {
+	if _, err := mssqlUUID([]byte{1, 2}); err == nil {
+		t.Error("expected error for short uniqueidentifier")
+	}
+}
+
+func TestDuckDBWriter_ExactDecimals(t *testing.T) {
+	dbFile := t.TempDir() + "/decimals.duckdb"
+	w := newDuckDBWriter(WriterOptions{Table: "ledger", Output: dbFile}, openDuckDB, scanln)
+	cols := []Column{
+		{Name: "wide", DatabaseType: "DECIMAL", Precision: 38, Scale: 10, Nullable: true},
+		{Name: "fee", DatabaseType: "MONEY", Nullable: true},
+	}
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{[]byte("1234567890123456789012345678.1234567890"), []byte("-922337203685477.5808")}}}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	db, err := sql.Open("duckdb", dbFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var wide, fee, feeType string
+	if err := db.QueryRow(`SELECT wide::VARCHAR, fee::VARCHAR, typeof(fee) FROM ledger`).Scan(&wide, &fee, &feeType); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if wide != "1234567890123456789012345678.1234567890" || fee != "-922337203685477.5808" || feeType != "DECIMAL(19,4)" {
+		t.Errorf("unexpected values: %s %s %s", wide, fee, feeType)
+	}
+}
@@ -1,95 +1,339 @@
 package dbexport
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"getmssql/i18n"
+	"io"
 	"os"
 	"strings"
 	"time"
 )
 
-// WriteFileOutput writes table data to a file in CSV, TSV, or JSON format.
-func WriteFileOutput(rows *sql.Rows, cols []string, table string, asTSV, asCSV bool, start time.Time) error {
-	var filename string
-	if asCSV {
-		filename = fmt.Sprintf("%s.csv", strings.ToLower(table))
-	} else if asTSV {
-		filename = fmt.Sprintf("%s.tsv", strings.ToLower(table))
-	} else {
-		filename = fmt.Sprintf("%s.json", strings.ToLower(table))
+func init() {
+	for _, format := range []string{"json", "jsonl", "csv", "tsv", "geojson"} {
+		format := format
+		RegisterFormat(format, func(opts WriterOptions) Writer {
+			return newFileWriter(opts, format)
+		})
+	}
+}
+
+// fileWriter writes table data to a file in CSV, TSV, JSON, JSON Lines or
+// GeoJSON format, optionally gzip compressed. By default the file is named
+// after the table with the format as extension.
+type fileWriter struct {
+	format    string
+	filename  string
+	overwrite OverwritePolicy
+	scanln    func(...interface{}) (int, error)
+	gzip      bool
+	file      *os.File
+	out       *countingWriter // counts the bytes that reach the file
+	gz        *gzip.Writer
+	buf       *bufio.Writer // buffers every write, ahead of gz when compressing
+	keys      [][]byte      // JSON Lines object keys, encoded once with their colon
+	cols      []string
+	spatial   []bool // geography and geometry columns, written as GeoJSON in JSON
+	geometry  int    // GeoJSON feature geometry column; -1 when there is none
+	dialect   CSVDialect
+	tsv       TSVEscaping
+	csv       *csvEncoder
+	first     bool
+}
+
+func newFileWriter(opts WriterOptions, format string) *fileWriter {
+	filename := opts.Output
+	if filename == "" {
+		filename = fmt.Sprintf("%s.%s", strings.ToLower(opts.Table), format)
+		if opts.Gzip {
+			filename += ".gz"
+		}
+	}
+	return &fileWriter{format: format, filename: filename, overwrite: opts.Overwrite, scanln: scanln, gzip: opts.Gzip, dialect: opts.CSV, tsv: opts.TSV}
+}
+
+// Open creates the output file and writes the header (CSV/TSV), the opening
+// bracket (JSON) or the start of the FeatureCollection (GeoJSON).
+func (w *fileWriter) Open(ctx context.Context, cols []Column) error {
+	if w.format == "csv" {
+		if err := w.dialect.Validate(); err != nil {
+			return err
+		}
+	}
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.out = &countingWriter{w: file}
+	var dst io.Writer = w.out
+	if w.gzip {
+		w.gz = gzip.NewWriter(w.out)
+		dst = w.gz
+	}
+	w.buf = bufio.NewWriterSize(dst, 64*1024)
+	w.cols = columnNames(cols)
+	w.spatial = make([]bool, len(cols))
+	w.geometry = -1
+	for i, col := range cols {
+		if w.spatial[i] = isSpatialType(col.DatabaseType); w.spatial[i] && w.geometry < 0 {
+			w.geometry = i
+		}
+	}
+	w.first = true
+	switch w.format {
+	case "csv":
+		w.csv = newCSVEncoder(w.buf, w.dialect)
+		if !w.dialect.NoHeader {
+			err = w.csv.writeHeader(w.cols)
+		}
+	case "tsv":
+		header := make([]interface{}, len(w.cols))
+		for i, name := range w.cols {
+			if w.tsv == TSVIANA && !ianaTSVSafe(name) {
+				file.Close()
+				return i18n.Errorf("values.column_error", name, i18n.Errorf("tsv.unescapable"))
+			}
+			header[i] = name
+		}
+		_, err = io.WriteString(w.buf, strings.Join(tsvFields(w.tsv, header), "\t")+"\n")
+	case "jsonl":
+		w.keys = make([][]byte, len(w.cols))
+		for i, name := range w.cols {
+			key, _ := json.Marshal(name)
+			w.keys[i] = append(key, ':')
+		}
+	case "geojson":
+		_, err = io.WriteString(w.buf, `{"type":"FeatureCollection","features":[`)
+	default:
+		_, err = io.WriteString(w.buf, "[")
 	}
-	file, err := os.Create(filename)
 	if err != nil {
-		return fmt.Errorf("error creating output file: %w", err)
+		file.Close()
+		return i18n.Errorf("file.write_error", err)
 	}
-	defer file.Close()
+	return nil
+}
 
-	rowCount := 0
-	if asCSV {
-		file.WriteString(strings.Join(cols, "||") + "\n")
-	} else if asTSV {
-		file.WriteString(strings.Join(cols, "\t") + "\n")
-	} else {
-		file.WriteString("[")
-	}
-	first := true
-	for rows.Next() {
-		rowMap := ScanRowMap(rows, cols)
-		if asCSV {
-			var rowVals []string
-			for _, colName := range cols {
-				val := rowMap[colName]
-				switch v := val.(type) {
-				case nil:
-					rowVals = append(rowVals, "")
-				case string:
-					rowVals = append(rowVals, v)
-				case []byte:
-					rowVals = append(rowVals, string(v))
-				default:
-					rowVals = append(rowVals, fmt.Sprintf("%v", v))
-				}
+// createFile creates the output file name, applying the overwrite policy
+// when it exists. OverwriteDefault replaces an existing file.
+func createFile(name string, policy OverwritePolicy, scanln func(...interface{}) (int, error)) (*os.File, error) {
+	if policy != OverwriteDefault {
+		if _, err := os.Stat(name); err == nil {
+			if err := confirmOverwrite(policy, i18n.T("file.exists", name), i18n.T("file.overwrite"), scanln); err != nil {
+				return nil, err
+			}
+		}
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, i18n.Errorf("file.create_error", err)
+	}
+	return file, nil
+}
+
+// prepareRow turns the WKT of geography and geometry columns into GeoJSON
+// geometry objects for the JSON formats; CSV and TSV keep the WKT. For IANA
+// TSV it rejects text containing a tab or a line break.
+func (w *fileWriter) prepareRow(row []interface{}) error {
+	if w.format == "tsv" && w.tsv == TSVIANA {
+		for i, v := range row {
+			if s, ok := v.(string); ok && !ianaTSVSafe(s) {
+				return i18n.Errorf("values.column_error", w.cols[i], i18n.Errorf("tsv.unescapable"))
 			}
-			file.WriteString(strings.Join(rowVals, "||") + "\n")
-		} else if asTSV {
-			var rowVals []string
-			for _, colName := range cols {
-				val := rowMap[colName]
-				switch v := val.(type) {
-				case nil:
-					rowVals = append(rowVals, "")
-				case string:
-					rowVals = append(rowVals, v)
-				case []byte:
-					rowVals = append(rowVals, string(v))
-				default:
-					rowVals = append(rowVals, fmt.Sprintf("%v", v))
+		}
+	}
+	if w.format != "json" && w.format != "jsonl" && w.format != "geojson" {
+		return nil
+	}
+	for i, v := range row {
+		if wkt, ok := v.(string); ok && w.spatial[i] {
+			g, err := wktToGeoJSON(wkt)
+			if err != nil {
+				return i18n.Errorf("values.column_error", w.cols[i], err)
+			}
+			row[i] = g
+		}
+	}
+	return nil
+}
+
+// WriteBatch appends one line (CSV/TSV/JSON Lines), one object (JSON) or one
+// Feature (GeoJSON) per row, then flushes the buffered output to the file.
+func (w *fileWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	for _, vals := range rows {
+		var err error
+		switch w.format {
+		case "csv":
+			err = w.csv.writeValues(vals)
+		case "tsv":
+			_, err = io.WriteString(w.buf, strings.Join(tsvFields(w.tsv, vals), "\t")+"\n")
+		case "jsonl":
+			err = w.writeJSONLine(vals)
+		default:
+			rowMap := make(map[string]interface{}, len(w.cols))
+			for i, colName := range w.cols {
+				if w.format == "geojson" && i == w.geometry {
+					continue
 				}
+				rowMap[colName] = vals[i]
+			}
+			var v interface{} = rowMap
+			if w.format == "geojson" {
+				v = w.feature(vals, rowMap)
+			}
+			var jsonBytes []byte
+			if jsonBytes, err = json.Marshal(v); err != nil {
+				return i18n.Errorf("file.write_error", err)
 			}
-			file.WriteString(strings.Join(rowVals, "\t") + "\n")
-		} else {
-			if !first {
-				file.WriteString(",")
+			if !w.first {
+				if _, err := io.WriteString(w.buf, ","); err != nil {
+					return i18n.Errorf("file.write_error", err)
+				}
 			}
-			first = false
-			jsonBytes, _ := json.Marshal(rowMap)
-			file.Write(jsonBytes)
+			w.first = false
+			_, err = w.buf.Write(jsonBytes)
+		}
+		if err != nil {
+			return i18n.Errorf("file.write_error", err)
 		}
-		rowCount++
-		if rowCount%1000 == 0 {
-			fmt.Printf("\rDownloaded %d rows...", rowCount)
+	}
+	if w.csv != nil {
+		if err := w.csv.flush(); err != nil {
+			return i18n.Errorf("file.write_error", err)
 		}
 	}
-	if !asCSV && !asTSV {
-		file.WriteString("]")
+	if err := w.buf.Flush(); err != nil {
+		return i18n.Errorf("file.write_error", err)
 	}
-	fmt.Printf("\rTotal rows downloaded: %d\n", rowCount)
-	elapsed := time.Since(start)
-	fmt.Printf("Table '%s' data written to %s in %s\n", table, filename, elapsed)
 	return nil
 }
 
+// writeJSONLine writes a row as a JSON object on its own line, with the keys
+// in column order rather than the sorted order of an encoded map.
+func (w *fileWriter) writeJSONLine(vals []interface{}) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, v := range vals {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.Write(w.keys[i])
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+	_, err := w.buf.Write(line.Bytes())
+	return err
+}
+
+// geoJSONFeature is a GeoJSON Feature object.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   interface{}            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// feature returns the GeoJSON Feature for a row: the geometry column as its
+// geometry (null when the table has none) and the other columns as properties.
+func (w *fileWriter) feature(vals []interface{}, properties map[string]interface{}) geoJSONFeature {
+	f := geoJSONFeature{Type: "Feature", Properties: properties}
+	if w.geometry >= 0 {
+		f.Geometry = vals[w.geometry]
+	}
+	return f
+}
+
+// Close writes the closing bracket (JSON) or closes the FeatureCollection
+// (GeoJSON), flushes the buffered and compressed output and closes the file.
+func (w *fileWriter) Close() error {
+	var end string
+	switch w.format {
+	case "json":
+		end = "]"
+	case "geojson":
+		end = "]}"
+	}
+	_, err := io.WriteString(w.buf, end)
+	if err == nil {
+		err = w.buf.Flush()
+	}
+	if err == nil && w.gz != nil {
+		err = w.gz.Close()
+	}
+	if err != nil {
+		w.file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.file.Close()
+}
+
+// Location returns the output file name.
+func (w *fileWriter) Location() string {
+	return w.filename
+}
+
+// BytesWritten returns the number of bytes written to the file.
+func (w *fileWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// formatDelimitedValues renders row values as strings for CSV and TSV output.
+func formatDelimitedValues(vals []interface{}, null string) []string {
+	rowVals := make([]string, len(vals))
+	for i, val := range vals {
+		switch v := val.(type) {
+		case nil:
+			rowVals[i] = null
+		case string:
+			rowVals[i] = v
+		case []byte:
+			// Binary values left to the writer are written as hex
+			rowVals[i] = hex.EncodeToString(v)
+		default:
+			rowVals[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	return rowVals
+}
+
+// WriteFileOutput writes table data to a file in CSV, TSV, or JSON format.
+func WriteFileOutput(rows *sql.Rows, cols []string, table string, asTSV, asCSV bool, start time.Time) error {
+	format := "json"
+	if asCSV {
+		format = "csv"
+	} else if asTSV {
+		format = "tsv"
+	}
+	w := newFileWriter(WriterOptions{Table: table}, format)
+	return runLegacyExport(rows, cols, table, w, start)
+}
+
 // WriteFileOutputRows is a wrapper for WriteFileOutput that accepts Rows interface
 func WriteFileOutputRows(rows Rows, cols []string, table string, asTSV, asCSV bool, start time.Time) error {
 	sqlRows, ok := rows.(*sql.Rows)
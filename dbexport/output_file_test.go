@@ -1,9 +1,19 @@
 package dbexport
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -135,3 +145,150 @@ func TestWriteFileOutput_EdgeCases_Local(t *testing.T) {
 		os.Remove(filename)
 	}
 }
+
+func TestFileWriter_OutputAndOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	out := dir + "/custom.csv"
+	if err := os.WriteFile(out, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to write existing file: %v", err)
+	}
+	cols := []Column{{Name: "a"}}
+
+	// OverwriteNever refuses an existing file
+	w := newFileWriter(WriterOptions{Table: "t", Output: out, Overwrite: OverwriteNever}, "csv")
+	if err := w.Open(context.Background(), cols); !errors.Is(err, ErrTargetExists) {
+		t.Errorf("expected ErrTargetExists, got: %v", err)
+	}
+
+	// OverwritePrompt declined aborts
+	w = newFileWriter(WriterOptions{Table: "t", Output: out, Overwrite: OverwritePrompt}, "csv")
+	w.scanln = func(a ...interface{}) (int, error) { return 0, nil }
+	if err := w.Open(context.Background(), cols); !errors.Is(err, ErrAborted) {
+		t.Errorf("expected ErrAborted, got: %v", err)
+	}
+
+	// OverwriteAlways replaces the file and counts bytes
+	w = newFileWriter(WriterOptions{Table: "t", Output: out, Overwrite: OverwriteAlways}, "csv")
+	if err := w.Open(context.Background(), cols); err != nil {
+		t.Fatalf("unexpected open error: %v", err)
+	}
+	if err := w.WriteBatch(context.Background(), [][]interface{}{{"x"}, {nil}}); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	data, _ := os.ReadFile(out)
+	if string(data) != "a\nx\n\n" {
+		t.Errorf("unexpected file content: %q", data)
+	}
+	if w.BytesWritten() != int64(len(data)) || w.Location() != out {
+		t.Errorf("unexpected bytes/location: %d %s", w.BytesWritten(), w.Location())
+	}
+}
+
+func TestFileWriter_JSONLines(t *testing.T) {
+	cols := []Column{{Name: "zeta", DatabaseType: "NVARCHAR"}, {Name: "alpha", DatabaseType: "DECIMAL"}, {Name: "mid", DatabaseType: "VARBINARY", Nullable: true}}
+	data := [][]interface{}{
+		{"line\nbreak", json.Number("1.50"), []byte{0xff}},
+		{`"quoted"`, json.Number("-2"), nil},
+	}
+	want := "{\"zeta\":\"line\\nbreak\",\"alpha\":1.50,\"mid\":\"/w==\"}\n" +
+		"{\"zeta\":\"\\\"quoted\\\"\",\"alpha\":-2,\"mid\":null}\n"
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		w := newFileWriter(WriterOptions{Table: "Orders", Gzip: compress}, "jsonl")
+		w.filename = filepath.Join(dir, w.filename)
+		if _, err := runExport(context.Background(), &staticRows{cols: columnNames(cols), data: data}, cols, w, exportRun{}); err != nil {
+			t.Fatalf("gzip=%v: export failed: %v", compress, err)
+		}
+		wantName := "orders.jsonl"
+		if compress {
+			wantName += ".gz"
+		}
+		if filepath.Base(w.Location()) != wantName {
+			t.Errorf("gzip=%v: file name %s, want %s", compress, filepath.Base(w.Location()), wantName)
+		}
+		raw, err := os.ReadFile(w.Location())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.BytesWritten() != int64(len(raw)) {
+			t.Errorf("gzip=%v: BytesWritten %d, file has %d bytes", compress, w.BytesWritten(), len(raw))
+		}
+		got := raw
+		if compress {
+			zr, err := gzip.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("not gzip: %v", err)
+			}
+			if got, err = io.ReadAll(zr); err != nil {
+				t.Fatalf("reading gzip: %v", err)
+			}
+		}
+		if string(got) != want {
+			t.Errorf("gzip=%v: got %q, want %q", compress, got, want)
+		}
+		// Every line is a JSON document on its own
+		for _, line := range strings.Split(strings.TrimSuffix(string(got), "\n"), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("gzip=%v: invalid JSON line %q", compress, line)
+			}
+		}
+	}
+}
+
+func TestFileWriter_GzipCSV(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.csv.gz")
+	w := newFileWriter(WriterOptions{Output: out, Gzip: true}, "csv")
+	cols := []Column{{Name: "a"}}
+	if _, err := runExport(context.Background(), &staticRows{cols: []string{"a"}, data: [][]interface{}{{"x"}}}, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("not gzip: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != "a\nx\n" {
+		t.Errorf("got %q, want %q", got, "a\nx\n")
+	}
+}
+
+func TestFileWriter_JSONMarshalError(t *testing.T) {
+	cols := []Column{{Name: "ratio", DatabaseType: "FLOAT"}}
+	for _, format := range []string{"json", "jsonl"} {
+		w := newFileWriter(WriterOptions{Table: "t", Output: filepath.Join(t.TempDir(), "t."+format)}, format)
+		rows := &staticRows{cols: []string{"ratio"}, data: [][]interface{}{{0.5}, {math.NaN()}}}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err == nil || !strings.Contains(err.Error(), "NaN") {
+			t.Errorf("%s: got %v, want an unsupported value error", format, err)
+		}
+	}
+}
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestFileWriter_JSONWriteError(t *testing.T) {
+	cols := []Column{{Name: "name", DatabaseType: "NVARCHAR"}}
+	for _, format := range []string{"json", "geojson", "jsonl"} {
+		w := newFileWriter(WriterOptions{Table: "t", Output: filepath.Join(t.TempDir(), "t."+format)}, format)
+		if err := w.Open(context.Background(), cols); err != nil {
+			t.Fatal(err)
+		}
+		w.buf = bufio.NewWriterSize(failWriter{}, 16)
+		err := w.WriteBatch(context.Background(), [][]interface{}{{strings.Repeat("x", 32)}})
+		if err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("%s: got %v, want the write error", format, err)
+		}
+		w.file.Close()
+	}
+}
@@ -0,0 +1,177 @@
+package dbexport
+
+import (
+	"context"
+	"fmt"
+	"getmssql/i18n"
+	"os"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+func init() {
+	RegisterFormat("parquet", func(opts WriterOptions) Writer {
+		return newParquetWriter(opts)
+	})
+}
+
+// DefaultParquetRowGroupSize is the number of rows per Parquet row group when
+// ParquetOptions.RowGroupSize is not set. A row group is held in memory until
+// it is complete, so this bounds the memory an export uses.
+const DefaultParquetRowGroupSize = 128 * 1024
+
+// ParquetCompression is the compression codec of Parquet column chunks.
+type ParquetCompression string
+
+const (
+	// ParquetSnappy compresses with Snappy. It is the default.
+	ParquetSnappy ParquetCompression = "snappy"
+	// ParquetZstd compresses with Zstandard.
+	ParquetZstd ParquetCompression = "zstd"
+	// ParquetGzip compresses with gzip.
+	ParquetGzip ParquetCompression = "gzip"
+	// ParquetNone writes uncompressed column chunks.
+	ParquetNone ParquetCompression = "none"
+)
+
+var parquetCodecs = map[ParquetCompression]compress.Compression{
+	ParquetSnappy: compress.Codecs.Snappy,
+	ParquetZstd:   compress.Codecs.Zstd,
+	ParquetGzip:   compress.Codecs.Gzip,
+	ParquetNone:   compress.Codecs.Uncompressed,
+}
+
+// ParseParquetCompression validates a compression codec as given on the
+// command line. The empty string selects ParquetSnappy.
+func ParseParquetCompression(s string) (ParquetCompression, error) {
+	c := ParquetCompression(strings.ToLower(s))
+	if c == "" {
+		return ParquetSnappy, nil
+	}
+	if _, ok := parquetCodecs[c]; !ok {
+		return "", i18n.Errorf("parquet.invalid_compression", s)
+	}
+	return c, nil
+}
+
+// ParquetOptions configures the parquet format.
+type ParquetOptions struct {
+	// RowGroupSize is the number of rows per row group; 0 selects
+	// DefaultParquetRowGroupSize.
+	RowGroupSize int64
+	// Compression is the codec of the column chunks. The zero value behaves
+	// like ParquetSnappy.
+	Compression ParquetCompression
+}
+
+// parquetWriter writes table data to a Parquet file, typed after the MSSQL
+// columns (see arrowType) and with column statistics. Each batch is appended
+// to the current row group, which is written out once it holds RowGroupSize
+// rows. By default the file is named after the table.
+type parquetWriter struct {
+	opts      WriterOptions
+	filename  string
+	overwrite OverwritePolicy
+	scanln    func(...interface{}) (int, error)
+	file      *os.File
+	out       *countingWriter
+	enc       *arrowEncoder
+	fw        *pqarrow.FileWriter
+}
+
+func newParquetWriter(opts WriterOptions) *parquetWriter {
+	filename := opts.Output
+	if filename == "" {
+		filename = fmt.Sprintf("%s.parquet", strings.ToLower(opts.Table))
+	}
+	return &parquetWriter{opts: opts, filename: filename, overwrite: opts.Overwrite, scanln: scanln}
+}
+
+// Open creates the output file and writes the Parquet header.
+func (w *parquetWriter) Open(ctx context.Context, cols []Column) error {
+	codec, err := ParseParquetCompression(string(w.opts.Parquet.Compression))
+	if err != nil {
+		return err
+	}
+	rowGroupSize := w.opts.Parquet.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultParquetRowGroupSize
+	}
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.out = &countingWriter{w: file}
+	w.enc = newArrowEncoder(cols, w.opts)
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(parquetCodecs[codec]),
+		parquet.WithMaxRowGroupLength(rowGroupSize),
+		parquet.WithStats(true),
+		parquet.WithCreatedBy("getmssql"),
+	)
+	// Storing the Arrow schema keeps the time zone of datetimeoffset columns
+	// and the unsigned tinyint for Arrow based readers
+	w.fw, err = pqarrow.NewFileWriter(w.enc.schema, w.out, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		w.enc.release()
+		file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// prepareRow converts a row of driver values for its Arrow column builders.
+func (w *parquetWriter) prepareRow(row []interface{}) error {
+	return w.enc.prepareRow(row)
+}
+
+// WriteBatch appends the rows to the current row group.
+func (w *parquetWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	rec := w.enc.record(rows)
+	defer rec.Release()
+	if err := w.fw.WriteBuffered(rec); err != nil {
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// NativeValues reports that the writer takes driver values and converts them
+// itself.
+func (w *parquetWriter) NativeValues() bool {
+	return true
+}
+
+// storesWKB reports that geography and geometry values are stored as WKB
+// binary columns, unless every column is text.
+func (w *parquetWriter) storesWKB() bool {
+	return w.opts.Types != TypesText
+}
+
+// Close writes the last row group and the file footer and closes the file.
+func (w *parquetWriter) Close() error {
+	// pqarrow leaves the file open: it only closes sinks that are io.Closers
+	err := w.fw.Close()
+	w.enc.release()
+	if err != nil {
+		w.file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.file.Close()
+}
+
+// Location returns the output file name.
+func (w *parquetWriter) Location() string {
+	return w.filename
+}
+
+// BytesWritten returns the number of bytes written to the file.
+func (w *parquetWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
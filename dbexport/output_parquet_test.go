@@ -0,0 +1,182 @@
+package dbexport
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// typedColumns and typedRows hold one column of each kind of MSSQL type, with
+// values as the driver returns them.
+var typedColumns = []Column{
+	{Name: "id", DatabaseType: "INT"},
+	{Name: "tiny", DatabaseType: "TINYINT", Nullable: true},
+	{Name: "price", DatabaseType: "DECIMAL", Precision: 10, Scale: 2, Nullable: true},
+	{Name: "ok", DatabaseType: "BIT", Nullable: true},
+	{Name: "born", DatabaseType: "DATE", Nullable: true},
+	{Name: "at", DatabaseType: "DATETIME2", Nullable: true},
+	{Name: "off", DatabaseType: "DATETIMEOFFSET", Nullable: true},
+	{Name: "name", DatabaseType: "NVARCHAR", Nullable: true},
+	{Name: "guid", DatabaseType: "UNIQUEIDENTIFIER", Nullable: true},
+	{Name: "data", DatabaseType: "VARBINARY", Nullable: true},
+	{Name: "ratio", DatabaseType: "FLOAT", Nullable: true},
+}
+
+func typedRows() [][]interface{} {
+	at := time.Date(2024, 2, 29, 13, 45, 10, 123456700, time.UTC)
+	off := time.Date(2024, 2, 29, 8, 45, 10, 0, time.FixedZone("", -5*3600))
+	var rows [][]interface{}
+	for i := int64(1); i <= 5; i++ {
+		rows = append(rows, []interface{}{i, i * 50, []byte("12.34"), i%2 == 0, at, at, off, "ñandú", guidBytes, []byte{0, byte(i)}, 0.5})
+	}
+	rows = append(rows, []interface{}{int64(6), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil})
+	return rows
+}
+
+// readParquet reads a Parquet file back as one array per column.
+func readParquet(t *testing.T, path string) (*file.Reader, *arrow.Schema, []arrow.Array) {
+	t.Helper()
+	rdr, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		t.Fatalf("not a Parquet file: %v", err)
+	}
+	t.Cleanup(func() { rdr.Close() })
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Release()
+	cols := make([]arrow.Array, tbl.NumCols())
+	for i := range cols {
+		if cols[i], err = array.Concatenate(tbl.Column(i).Data().Chunks(), memory.DefaultAllocator); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rdr, tbl.Schema(), cols
+}
+
+func TestParquetWriter_Types(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "t.parquet")
+	opts := WriterOptions{Table: "t", Output: out, Parquet: ParquetOptions{RowGroupSize: 2, Compression: ParquetZstd}}
+	w := newParquetWriter(opts)
+	rows := &staticRows{cols: columnNames(typedColumns), data: typedRows()}
+	res, err := runExport(context.Background(), rows, typedColumns, w, exportRun{})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if res.RowsWritten != 6 || res.BytesWritten == 0 {
+		t.Errorf("rows %d, bytes %d", res.RowsWritten, res.BytesWritten)
+	}
+
+	rdr, schema, cols := readParquet(t, out)
+	wantTypes := []string{"int32", "uint8", "decimal(10, 2)", "bool", "date32", "timestamp[us]", "timestamp[us, tz=UTC]", "utf8", "utf8", "binary", "float64"}
+	for i, want := range wantTypes {
+		if got := schema.Field(i).Type.String(); got != want {
+			t.Errorf("column %s: type %s, want %s", schema.Field(i).Name, got, want)
+		}
+	}
+	if schema.Field(0).Nullable || !schema.Field(1).Nullable {
+		t.Error("nullability does not follow the columns")
+	}
+
+	if got := cols[1].(*array.Uint8).Value(4); got != 250 {
+		t.Errorf("tiny: got %d, want 250", got)
+	}
+	if got := cols[2].(*array.Decimal128).Value(0).ToString(2); got != "12.34" {
+		t.Errorf("price: got %s, want 12.34", got)
+	}
+	if got := cols[4].(*array.Date32).Value(0).ToTime().Format("2006-01-02"); got != "2024-02-29" {
+		t.Errorf("born: got %s", got)
+	}
+	if got := cols[5].(*array.Timestamp).Value(0).ToTime(arrow.Microsecond); !got.Equal(time.Date(2024, 2, 29, 13, 45, 10, 123456000, time.UTC)) {
+		t.Errorf("at: got %s", got)
+	}
+	if got := cols[6].(*array.Timestamp).Value(0).ToTime(arrow.Microsecond); !got.Equal(time.Date(2024, 2, 29, 13, 45, 10, 0, time.UTC)) {
+		t.Errorf("off: got %s, want 13:45:10 UTC", got)
+	}
+	if got := cols[8].(*array.String).Value(0); got != "6f9619ff-8b86-d011-b42d-00c04fc964ff" {
+		t.Errorf("guid: got %s", got)
+	}
+	for i, col := range cols {
+		if i > 0 && !col.IsNull(5) {
+			t.Errorf("column %s: row 6 is not NULL", schema.Field(i).Name)
+		}
+	}
+
+	md := rdr.MetaData()
+	if md.NumRowGroups() != 3 {
+		t.Errorf("got %d row groups, want 3", md.NumRowGroups())
+	}
+	chunk, err := md.RowGroup(0).ColumnChunk(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Compression() != compress.Codecs.Zstd {
+		t.Errorf("compression %s, want zstd", chunk.Compression())
+	}
+	if ok, _ := chunk.StatsSet(); !ok {
+		t.Error("no column statistics")
+	}
+	if stats, err := chunk.Statistics(); err != nil || stats == nil || !stats.HasMinMax() {
+		t.Errorf("no min/max statistics (err: %v)", err)
+	}
+}
+
+func TestParquetWriter_RowErrors(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "t.parquet")
+	cols := []Column{{Name: "tiny", DatabaseType: "TINYINT", Nullable: true}, {Name: "d", DatabaseType: "DECIMAL", Precision: 4, Scale: 2, Nullable: true}}
+	data := [][]interface{}{{int64(1), []byte("1.50")}, {int64(300), []byte("1.00")}, {int64(2), []byte("123.45")}, {int64(3), nil}}
+	run := exportRun{rowErrs: newRowErrorHandler(RowErrorSkip, "")}
+	res, err := runExport(context.Background(), &staticRows{cols: columnNames(cols), data: data}, cols, newParquetWriter(WriterOptions{Output: out}), run)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if res.RowsWritten != 2 || res.RowsRejected != 2 {
+		t.Errorf("written %d, rejected %d; want 2 and 2", res.RowsWritten, res.RowsRejected)
+	}
+	_, _, got := readParquet(t, out)
+	if got[0].Len() != 2 || got[0].(*array.Uint8).Value(1) != 3 {
+		t.Errorf("unexpected rows: %v", got[0])
+	}
+}
+
+func TestParquetWriter_TextTypes(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "t.parquet")
+	w := newParquetWriter(WriterOptions{Output: out, Types: TypesText, Parquet: ParquetOptions{Compression: ParquetNone}})
+	if _, err := runExport(context.Background(), &staticRows{cols: columnNames(typedColumns), data: typedRows()}, typedColumns, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	_, schema, cols := readParquet(t, out)
+	for _, f := range schema.Fields() {
+		if f.Type.ID() != arrow.STRING {
+			t.Errorf("column %s: type %s, want utf8", f.Name, f.Type)
+		}
+	}
+	if got := cols[5].(*array.String).Value(0); got != "2024-02-29T13:45:10.1234567" {
+		t.Errorf("at: got %q", got)
+	}
+}
+
+func TestParseParquetCompression(t *testing.T) {
+	for in, want := range map[string]ParquetCompression{"": ParquetSnappy, "ZSTD": ParquetZstd, "gzip": ParquetGzip, "none": ParquetNone} {
+		if got, err := ParseParquetCompression(in); err != nil || got != want {
+			t.Errorf("ParseParquetCompression(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseParquetCompression("lz4"); err == nil || !strings.Contains(err.Error(), "snappy, zstd, gzip or none") {
+		t.Errorf("lz4: got %v", err)
+	}
+}
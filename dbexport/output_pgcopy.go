@@ -0,0 +1,258 @@
+package dbexport
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"getmssql/i18n"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFormat("pgcopy", func(opts WriterOptions) Writer {
+		return newPGCopyWriter(opts)
+	})
+}
+
+// pgcopyWriter writes table data in the PostgreSQL COPY text format, with
+// \N for NULL and the escapes of tsvEscaper, and a psql script next to it
+// that creates the table (as the sql format does for postgres) and loads the
+// data with \copy. By default the files are named after the table, with the
+// .copy and .load.sql extensions.
+type pgcopyWriter struct {
+	table     string
+	filename  string
+	script    string
+	overwrite OverwritePolicy
+	scanln    func(...interface{}) (int, error)
+	gzip      bool
+	types     TypeMode
+	timeZone  *time.Location
+	conv      valueConverter
+	file      *os.File
+	scriptOut *os.File // written by Close
+	out       *countingWriter
+	gz        *gzip.Writer
+	buf       *bufio.Writer
+	cols      []Column
+	line      []byte
+}
+
+func newPGCopyWriter(opts WriterOptions) *pgcopyWriter {
+	filename := opts.Output
+	if filename == "" {
+		filename = fmt.Sprintf("%s.copy", strings.ToLower(opts.Table))
+		if opts.Gzip {
+			filename += ".gz"
+		}
+	}
+	conv := newValueConverter(opts)
+	conv.binary = BinaryAuto // binary values are written as bytea hex
+	if opts.Types == TypesText {
+		conv = newTextConverter(opts)
+	}
+	return &pgcopyWriter{
+		table:     opts.Table,
+		filename:  filename,
+		script:    pgcopyScriptName(filename),
+		overwrite: opts.Overwrite,
+		scanln:    scanln,
+		gzip:      opts.Gzip,
+		types:     opts.Types,
+		timeZone:  opts.TimeZone,
+		conv:      conv,
+	}
+}
+
+// pgcopyScriptName returns the name of the load script of a data file: the
+// data file name with its extensions, including .gz, replaced by .load.sql,
+// which keeps it apart from the script of the sql format.
+func pgcopyScriptName(filename string) string {
+	base := strings.TrimSuffix(filename, ".gz")
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".load.sql"
+}
+
+// Open creates the data file and then the load script, which Close writes
+// once the data is complete. When the script cannot be created the data file
+// is removed again.
+func (w *pgcopyWriter) Open(ctx context.Context, cols []Column) error {
+	if len(cols) == 0 {
+		return i18n.Errorf("writer.no_columns")
+	}
+	w.cols = cols
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	script, err := createFile(w.script, w.overwrite, w.scanln)
+	if err != nil {
+		file.Close()
+		os.Remove(w.filename)
+		return err
+	}
+	w.file = file
+	w.scriptOut = script
+	w.out = &countingWriter{w: file}
+	var dst io.Writer = w.out
+	if w.gzip {
+		w.gz = gzip.NewWriter(w.out)
+		dst = w.gz
+	}
+	w.buf = bufio.NewWriterSize(dst, 64*1024)
+	return nil
+}
+
+// writeScript writes the psql script that creates the table and loads the
+// data file, in one transaction, and closes it. \copy reads the file on the
+// client, relative to the directory psql runs in; compressed files are read
+// through gzip.
+func (w *pgcopyWriter) writeScript() error {
+	file := w.scriptOut
+	table := quoteSQLIdent(SQLPostgres, strings.ToLower(w.table))
+	source := "'" + strings.ReplaceAll(w.filename, "'", "''") + "'"
+	if w.gzip {
+		source = "PROGRAM 'gzip -dc " + strings.ReplaceAll(shellQuote(w.filename), "'", "''") + "'"
+	}
+	script := "BEGIN;\n" + createTableSQL(SQLPostgres, table, w.cols, w.types) +
+		fmt.Sprintf("\\copy %s (%s) FROM %s WITH (FORMAT text)\n", table, sqlColumnList(SQLPostgres, w.cols), source) +
+		"COMMIT;\n"
+	_, err := io.WriteString(file, script)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// prepareRow replaces the driver values of a row with their escaped COPY
+// fields, see pgcopyText.
+func (w *pgcopyWriter) prepareRow(row []interface{}) error {
+	for i, v := range row {
+		if v == nil {
+			row[i] = tsvNull
+			continue
+		}
+		text, err := w.pgcopyText(w.cols[i], v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", w.cols[i].Name, err)
+		}
+		row[i] = tsvEscaper.Replace(text)
+	}
+	return nil
+}
+
+// pgcopyText returns the text PostgreSQL reads for a driver value of col in a
+// column declared by postgresColumnType: booleans as t and f, binary values
+// as bytea hex and dates and times as the sql format writes them. With
+// TypesText every value is its text form. Text cannot hold NUL characters,
+// which fail the row.
+func (w *pgcopyWriter) pgcopyText(col Column, v interface{}) (string, error) {
+	if w.types != TypesText {
+		switch x := v.(type) {
+		case time.Time:
+			return formatSQLTime(SQLPostgres, w.timeZone, col, x), nil
+		case bool:
+			if x {
+				return "t", nil
+			}
+			return "f", nil
+		case int64:
+			return strconv.FormatInt(x, 10), nil
+		case float64:
+			return strconv.FormatFloat(x, 'g', -1, 64), nil
+		case float32:
+			return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+		}
+	}
+	val, err := w.conv.convert(col, v)
+	if err != nil {
+		return "", err
+	}
+	var text string
+	switch x := val.(type) {
+	case json.Number:
+		text = string(x)
+	case []byte:
+		text = `\x` + hex.EncodeToString(x)
+	default:
+		text = formatDelimitedValues([]interface{}{val}, "")[0]
+	}
+	if strings.ContainsRune(text, 0) {
+		return "", i18n.Errorf("sql.nul_character", SQLPostgres)
+	}
+	return text, nil
+}
+
+// WriteBatch writes one line per row and flushes the buffered output.
+func (w *pgcopyWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	for _, vals := range rows {
+		w.line = w.line[:0]
+		for i, field := range vals {
+			if i > 0 {
+				w.line = append(w.line, '\t')
+			}
+			w.line = append(w.line, field.(string)...)
+		}
+		w.line = append(w.line, '\n')
+		if _, err := w.buf.Write(w.line); err != nil {
+			return i18n.Errorf("file.write_error", err)
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// NativeValues reports that the writer takes driver values and converts them
+// itself.
+func (w *pgcopyWriter) NativeValues() bool {
+	return true
+}
+
+// Close flushes the buffered and compressed output, closes the data file and
+// writes the load script. The script is removed when the data cannot be
+// written.
+func (w *pgcopyWriter) Close() error {
+	err := w.buf.Flush()
+	if err == nil && w.gz != nil {
+		err = w.gz.Close()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		w.scriptOut.Close()
+		os.Remove(w.script)
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.writeScript()
+}
+
+// Location returns the data file and the load script.
+func (w *pgcopyWriter) Location() string {
+	return fmt.Sprintf("%s (load script: %s)", w.filename, w.script)
+}
+
+// BytesWritten returns the number of bytes written to the data file.
+func (w *pgcopyWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
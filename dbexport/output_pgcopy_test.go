@@ -0,0 +1,166 @@
+package dbexport
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPGCopyWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := newPGCopyWriter(WriterOptions{Table: "T"})
+	w.filename = filepath.Join(dir, w.filename)
+	w.script = pgcopyScriptName(w.filename)
+	rows := &staticRows{cols: columnNames(typedColumns), data: typedRows()}
+	if _, err := runExport(context.Background(), rows, typedColumns, w, exportRun{batchSize: 4}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if filepath.Base(w.filename) != "t.copy" || filepath.Base(w.script) != "t.load.sql" {
+		t.Errorf("files %s and %s, want t.copy and t.load.sql", w.filename, w.script)
+	}
+
+	data, err := os.ReadFile(w.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want 6 without a header:\n%s", len(lines), data)
+	}
+	want := "2\t100\t12.34\tt\t2024-02-29\t2024-02-29 13:45:10.1234567\t2024-02-29 08:45:10-05:00\tñandú\t6f9619ff-8b86-d011-b42d-00c04fc964ff\t\\\\x0002\t0.5"
+	if lines[1] != want {
+		t.Errorf("line 2:\n%q\nwant\n%q", lines[1], want)
+	}
+	if lines[5] != "6"+strings.Repeat("\t\\N", 10) {
+		t.Errorf("NULL line %q", lines[5])
+	}
+
+	script, err := os.ReadFile(w.script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"BEGIN;\nCREATE TABLE \"t\" (\n    \"id\" integer NOT NULL,\n",
+		"    \"ratio\" double precision\n);\n",
+		"\\copy \"t\" (\"id\", \"tiny\", \"price\", \"ok\", \"born\", \"at\", \"off\", \"name\", \"guid\", \"data\", \"ratio\") FROM '" + w.filename + "' WITH (FORMAT text)\nCOMMIT;\n",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("script lacks %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestPGCopyWriter_Values(t *testing.T) {
+	w := newPGCopyWriter(WriterOptions{Table: "t"})
+	w.cols = []Column{{Name: "a", DatabaseType: "NVARCHAR"}, {Name: "b", DatabaseType: "NVARCHAR"}, {Name: "c", DatabaseType: "VARCHAR"}}
+	row := []interface{}{"", nil, "tab\there\nnew \\ line"}
+	if err := w.prepareRow(row); err != nil {
+		t.Fatal(err)
+	}
+	// An empty string and NULL stay apart
+	if row[0] != "" || row[1] != `\N` || row[2] != `tab\there\nnew \\ line` {
+		t.Errorf("got %q", row)
+	}
+	if err := w.prepareRow([]interface{}{"a\x00", nil, nil}); err == nil || !strings.Contains(err.Error(), "NUL") {
+		t.Errorf("got %v, want a NUL character error", err)
+	}
+}
+
+func TestPGCopyWriter_Gzip(t *testing.T) {
+	dir := t.TempDir()
+	w := newPGCopyWriter(WriterOptions{Table: "t", Output: filepath.Join(dir, "it's.copy.gz"), Gzip: true})
+	cols := []Column{{Name: "n", DatabaseType: "INT"}}
+	rows := &staticRows{cols: []string{"n"}, data: [][]interface{}{{int64(1)}, {int64(2)}}}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{batchSize: 10}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if w.script != filepath.Join(dir, "it's.load.sql") {
+		t.Errorf("script %s", w.script)
+	}
+	f, err := os.Open(w.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("not gzip: %v", err)
+	}
+	if data, _ := io.ReadAll(gz); string(data) != "1\n2\n" {
+		t.Errorf("data %q", data)
+	}
+	script, _ := os.ReadFile(w.script)
+	want := "FROM PROGRAM 'gzip -dc ''" + filepath.Join(dir, "it") + `''\''''s.copy.gz''' WITH (FORMAT text)`
+	if !strings.Contains(string(script), want) {
+		t.Errorf("script lacks %s:\n%s", want, script)
+	}
+}
+
+func TestPGCopyScriptName(t *testing.T) {
+	for in, want := range map[string]string{
+		"orders.copy":    "orders.load.sql",
+		"orders.copy.gz": "orders.load.sql",
+		"dir/data":       "dir/data.load.sql",
+		"orders.sql":     "orders.load.sql",
+	} {
+		if got := pgcopyScriptName(in); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestPGCopyWriter_ScriptLast(t *testing.T) {
+	dir := t.TempDir()
+	data, script := filepath.Join(dir, "t.copy"), filepath.Join(dir, "t.load.sql")
+	cols := []Column{{Name: "n", DatabaseType: "INT"}}
+
+	// A declined data file leaves an existing script alone
+	if err := os.WriteFile(data, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("old script"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := newPGCopyWriter(WriterOptions{Table: "t", Output: data, Overwrite: OverwriteNever})
+	if err := w.Open(context.Background(), cols); !errors.Is(err, ErrTargetExists) {
+		t.Errorf("expected ErrTargetExists, got: %v", err)
+	}
+	if b, _ := os.ReadFile(script); string(b) != "old script" {
+		t.Errorf("script replaced with %q", b)
+	}
+
+	// A declined script removes the new data file again
+	os.Remove(data)
+	w = newPGCopyWriter(WriterOptions{Table: "t", Output: data, Overwrite: OverwritePrompt})
+	w.scanln = func(a ...interface{}) (int, error) { return 0, nil }
+	if err := w.Open(context.Background(), cols); !errors.Is(err, ErrAborted) {
+		t.Errorf("expected ErrAborted, got: %v", err)
+	}
+	if _, err := os.Stat(data); !os.IsNotExist(err) {
+		t.Errorf("data file left behind: %v", err)
+	}
+
+	// The script is written by Close
+	os.Remove(script)
+	w = newPGCopyWriter(WriterOptions{Table: "t", Output: data})
+	if err := w.Open(context.Background(), cols); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(script); len(b) != 0 {
+		t.Errorf("script written before the data: %q", b)
+	}
+	if err := w.WriteBatch(context.Background(), [][]interface{}{{"1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(script); !strings.Contains(string(b), "\\copy") {
+		t.Errorf("script %q", b)
+	}
+}
@@ -0,0 +1,571 @@
+package dbexport
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"getmssql/i18n"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFormat("sql", func(opts WriterOptions) Writer {
+		return newSQLWriter(opts)
+	})
+}
+
+// SQLDialect is the target database of the sql format.
+type SQLDialect string
+
+const (
+	// SQLMSSQL writes Transact-SQL for SQL Server. It is the default.
+	SQLMSSQL SQLDialect = "mssql"
+	// SQLPostgres writes SQL for PostgreSQL.
+	SQLPostgres SQLDialect = "postgres"
+	// SQLMySQL writes SQL for MySQL and MariaDB.
+	SQLMySQL SQLDialect = "mysql"
+	// SQLSQLite writes SQL for SQLite.
+	SQLSQLite SQLDialect = "sqlite"
+)
+
+// ParseSQLDialect validates a dialect name as given on the command line.
+// The empty string selects SQLMSSQL.
+func ParseSQLDialect(s string) (SQLDialect, error) {
+	switch d := SQLDialect(strings.ToLower(s)); d {
+	case "":
+		return SQLMSSQL, nil
+	case SQLMSSQL, SQLPostgres, SQLMySQL, SQLSQLite:
+		return d, nil
+	}
+	return "", i18n.Errorf("sql.invalid_dialect", s)
+}
+
+// DefaultSQLInsertRows is the number of rows per INSERT statement when
+// SQLOptions.InsertRows is not set. It is the most SQL Server accepts.
+const DefaultSQLInsertRows = 1000
+
+// SQLOptions configures the sql format.
+type SQLOptions struct {
+	// Dialect is the target database. The zero value behaves like SQLMSSQL.
+	Dialect SQLDialect
+	// InsertRows is the number of rows per INSERT statement; 0 selects
+	// DefaultSQLInsertRows. SQL Server accepts at most 1000.
+	InsertRows int
+	// Transactions wraps the INSERT statements of each batch of rows in a
+	// transaction, so that a failed load keeps whole batches.
+	Transactions bool
+}
+
+// Validate reports whether the options can be used.
+func (o SQLOptions) Validate() error {
+	if _, err := ParseSQLDialect(string(o.Dialect)); err != nil {
+		return err
+	}
+	if o.InsertRows < 0 || (o.dialect() == SQLMSSQL && o.InsertRows > DefaultSQLInsertRows) {
+		return i18n.Errorf("sql.bad_insert_rows", o.InsertRows, o.dialect())
+	}
+	return nil
+}
+
+func (o SQLOptions) dialect() SQLDialect {
+	if o.Dialect == "" {
+		return SQLMSSQL
+	}
+	return SQLDialect(strings.ToLower(string(o.Dialect)))
+}
+
+// sqlWriter writes table data as a SQL script: a CREATE TABLE statement with
+// the source columns mapped to the types of the target database (see
+// sqlColumnType), followed by multi-row INSERT statements. By default the
+// file is named after the table.
+type sqlWriter struct {
+	table      string
+	filename   string
+	overwrite  OverwritePolicy
+	scanln     func(...interface{}) (int, error)
+	types      TypeMode
+	opts       SQLOptions
+	dialect    SQLDialect
+	insertRows int
+	conv       valueConverter
+	timeZone   *time.Location
+	file       *os.File
+	out        *countingWriter
+	buf        *bufio.Writer
+	cols       []Column
+	insert     string // INSERT INTO ... VALUES
+}
+
+func newSQLWriter(opts WriterOptions) *sqlWriter {
+	filename := opts.Output
+	if filename == "" {
+		filename = fmt.Sprintf("%s.sql", strings.ToLower(opts.Table))
+	}
+	conv := newValueConverter(opts)
+	conv.binary = BinaryAuto // binary values are written as binary literals
+	if opts.Types == TypesText {
+		conv = newTextConverter(opts)
+	}
+	insertRows := opts.SQL.InsertRows
+	if insertRows == 0 {
+		insertRows = DefaultSQLInsertRows
+	}
+	return &sqlWriter{
+		table:      opts.Table,
+		filename:   filename,
+		overwrite:  opts.Overwrite,
+		scanln:     scanln,
+		types:      opts.Types,
+		opts:       opts.SQL,
+		dialect:    opts.SQL.dialect(),
+		insertRows: insertRows,
+		conv:       conv,
+		timeZone:   opts.TimeZone,
+	}
+}
+
+// Open creates the output file and writes the CREATE TABLE statement.
+func (w *sqlWriter) Open(ctx context.Context, cols []Column) error {
+	if err := w.opts.Validate(); err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return i18n.Errorf("writer.no_columns")
+	}
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.out = &countingWriter{w: file}
+	w.buf = bufio.NewWriterSize(w.out, 64*1024)
+	w.cols = cols
+
+	table := quoteSQLIdent(w.dialect, strings.ToLower(w.table))
+	w.buf.WriteString(createTableSQL(w.dialect, table, cols, w.types))
+	w.insert = fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", table, sqlColumnList(w.dialect, cols))
+	if err := w.buf.Flush(); err != nil {
+		file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// createTableSQL returns the CREATE TABLE statement of the quoted table with
+// cols, declared as sqlColumnType says.
+func createTableSQL(d SQLDialect, table string, cols []Column, mode TypeMode) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", table)
+	for i, col := range cols {
+		def := sqlColumnType(d, col, mode)
+		if !col.Nullable && mode != TypesText {
+			def += " NOT NULL"
+		}
+		sep := ","
+		if i == len(cols)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "    %s %s%s\n", quoteSQLIdent(d, col.Name), def, sep)
+	}
+	if d == SQLMySQL {
+		b.WriteString(") CHARACTER SET utf8mb4;\n")
+	} else {
+		b.WriteString(");\n")
+	}
+	return b.String()
+}
+
+// sqlColumnList returns the quoted names of cols, separated by commas.
+func sqlColumnList(d SQLDialect, cols []Column) string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = quoteSQLIdent(d, col.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// quoteSQLIdent quotes a table or column name for the dialect.
+func quoteSQLIdent(d SQLDialect, name string) string {
+	switch d {
+	case SQLMSSQL:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	case SQLMySQL:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlColumnType maps an MSSQL column to its type in the target database.
+// SQL Server gets the source type back; the other dialects get the closest
+// type that holds the values without loss, or text. With TypesText every
+// column is text.
+func sqlColumnType(d SQLDialect, col Column, mode TypeMode) string {
+	typ := strings.ToUpper(col.DatabaseType)
+	switch d {
+	case SQLMSSQL:
+		if mode == TypesText {
+			return "NVARCHAR(MAX)"
+		}
+		return mssqlColumnType(col, typ)
+	case SQLPostgres:
+		if mode == TypesText {
+			return "text"
+		}
+		return postgresColumnType(col, typ)
+	case SQLMySQL:
+		if mode == TypesText {
+			return "LONGTEXT"
+		}
+		return mysqlColumnType(col, typ)
+	}
+	if mode == TypesText || isSpatialType(typ) {
+		// Spatial values are written as WKT
+		return "TEXT"
+	}
+	return sqliteAffinity(col)
+}
+
+// mssqlColumnType returns the SQL Server type of col, with its length or
+// precision when the driver reports them. rowversion values cannot be
+// inserted, so they go to a BINARY(8) column.
+func mssqlColumnType(col Column, typ string) string {
+	switch typ {
+	case "TINYINT", "SMALLINT", "INT", "BIGINT", "BIT", "REAL", "FLOAT", "MONEY", "SMALLMONEY",
+		"DATE", "TIME", "DATETIME", "DATETIME2", "SMALLDATETIME", "DATETIMEOFFSET",
+		"UNIQUEIDENTIFIER", "GEOGRAPHY", "GEOMETRY", "XML", "TEXT", "NTEXT", "IMAGE":
+		return typ
+	case "DECIMAL", "NUMERIC":
+		if p := decimalPrecision(col); p > 0 && p <= 38 {
+			return fmt.Sprintf("%s(%d,%d)", typ, p, decimalScale(col))
+		}
+		return typ
+	case "TIMESTAMP", "ROWVERSION":
+		return "BINARY(8)"
+	case "CHAR", "VARCHAR", "BINARY", "VARBINARY":
+		return mssqlSizedType(typ, col.Length, 8000)
+	case "NCHAR", "NVARCHAR":
+		return mssqlSizedType(typ, col.Length, 4000)
+	}
+	return "NVARCHAR(MAX)"
+}
+
+// mssqlSizedType returns typ(length), or its MAX variant for lengths over max
+// or unknown.
+func mssqlSizedType(typ string, length, max int64) string {
+	if length > 0 && length <= max {
+		return fmt.Sprintf("%s(%d)", typ, length)
+	}
+	switch typ {
+	case "CHAR", "VARCHAR":
+		return "VARCHAR(MAX)"
+	case "NCHAR", "NVARCHAR":
+		return "NVARCHAR(MAX)"
+	}
+	return "VARBINARY(MAX)"
+}
+
+// postgresColumnType returns the PostgreSQL type of col. tinyint widens to
+// smallint, as PostgreSQL has no single-byte integer.
+func postgresColumnType(col Column, typ string) string {
+	switch typ {
+	case "TINYINT", "SMALLINT":
+		return "smallint"
+	case "INT":
+		return "integer"
+	case "BIGINT":
+		return "bigint"
+	case "BIT":
+		return "boolean"
+	case "REAL":
+		return "real"
+	case "FLOAT":
+		return "double precision"
+	case "DATE":
+		return "date"
+	case "TIME":
+		return "time"
+	case "DATETIME", "DATETIME2", "SMALLDATETIME":
+		return "timestamp"
+	case "DATETIMEOFFSET":
+		return "timestamptz"
+	case "UNIQUEIDENTIFIER":
+		return "uuid"
+	case "CHAR", "NCHAR":
+		if col.Length > 0 && col.Length <= 8000 {
+			return fmt.Sprintf("char(%d)", col.Length)
+		}
+	case "VARCHAR", "NVARCHAR":
+		if col.Length > 0 && col.Length <= 8000 {
+			return fmt.Sprintf("varchar(%d)", col.Length)
+		}
+	}
+	if isDecimalType(typ) {
+		if p := decimalPrecision(col); p > 0 && p <= 38 {
+			return fmt.Sprintf("numeric(%d,%d)", p, decimalScale(col))
+		}
+		return "numeric"
+	}
+	if isBinaryType(typ) {
+		return "bytea"
+	}
+	return "text"
+}
+
+// mysqlColumnType returns the MySQL type of col. datetimeoffset values are
+// converted to UTC, as MySQL has no type that keeps an offset, and strings
+// longer than 255 characters are LONGTEXT to stay within the row size limit.
+func mysqlColumnType(col Column, typ string) string {
+	switch typ {
+	case "TINYINT":
+		return "TINYINT UNSIGNED"
+	case "SMALLINT", "INT", "BIGINT", "DATE":
+		return typ
+	case "BIT":
+		return "BOOLEAN"
+	case "REAL":
+		return "FLOAT"
+	case "FLOAT":
+		return "DOUBLE"
+	case "TIME":
+		return "TIME(6)"
+	case "DATETIME", "DATETIME2", "SMALLDATETIME", "DATETIMEOFFSET":
+		return "DATETIME(6)"
+	case "UNIQUEIDENTIFIER":
+		return "CHAR(36)"
+	case "CHAR", "NCHAR":
+		if col.Length > 0 && col.Length <= 255 {
+			return fmt.Sprintf("CHAR(%d)", col.Length)
+		}
+	case "VARCHAR", "NVARCHAR":
+		if col.Length > 0 && col.Length <= 255 {
+			return fmt.Sprintf("VARCHAR(%d)", col.Length)
+		}
+	}
+	if isDecimalType(typ) {
+		if p := decimalPrecision(col); p > 0 && p <= 38 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", p, decimalScale(col))
+		}
+	}
+	if isBinaryType(typ) {
+		return "LONGBLOB"
+	}
+	return "LONGTEXT"
+}
+
+// prepareRow replaces the driver values of a row with their SQL literals, see
+// literal.
+func (w *sqlWriter) prepareRow(row []interface{}) error {
+	for i, v := range row {
+		lit, err := w.literal(w.cols[i], v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", w.cols[i].Name, err)
+		}
+		row[i] = lit
+	}
+	return nil
+}
+
+// literal returns the SQL literal of a driver value read from col: numbers
+// and decimals unquoted, booleans as 1/0 or TRUE/FALSE, binary values as hex
+// literals, and everything else as a string literal. With TypesText every
+// value is a string literal of its text form.
+func (w *sqlWriter) literal(col Column, v interface{}) (string, error) {
+	if v == nil {
+		return "NULL", nil
+	}
+	if w.types != TypesText {
+		switch x := v.(type) {
+		case time.Time:
+			return w.quoteString(formatSQLTime(w.dialect, w.timeZone, col, x))
+		case bool:
+			return w.boolLiteral(x), nil
+		case int64:
+			return strconv.FormatInt(x, 10), nil
+		case float64:
+			return strconv.FormatFloat(x, 'g', -1, 64), nil
+		case float32:
+			return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+		}
+	}
+	val, err := w.conv.convert(col, v)
+	if err != nil {
+		return "", err
+	}
+	if w.types != TypesText {
+		switch x := val.(type) {
+		case json.Number:
+			// SQLite would read wide decimals as REAL before storing them as TEXT
+			if w.dialect != SQLSQLite || sqliteAffinity(col) != "TEXT" {
+				return string(x), nil
+			}
+		case []byte:
+			return w.binaryLiteral(x), nil
+		}
+	}
+	return w.quoteString(formatDelimitedValues([]interface{}{val}, "")[0])
+}
+
+// formatSQLTime formats a date or time value for the column type and dialect.
+// datetime keeps the milliseconds SQL Server accepts for it and MySQL the
+// microseconds of DATETIME(6); datetimeoffset values are moved to
+// timeZone, if set, and to UTC for MySQL.
+func formatSQLTime(d SQLDialect, timeZone *time.Location, col Column, t time.Time) string {
+	typ := strings.ToUpper(col.DatabaseType)
+	if typ == "DATETIMEOFFSET" || typ == "" {
+		if timeZone != nil {
+			t = t.In(timeZone)
+		}
+		if d == SQLMySQL {
+			t = t.UTC()
+			typ = "DATETIME2"
+		}
+	}
+	fraction := ".9999999"
+	if d == SQLMySQL {
+		fraction = ".999999"
+	}
+	sep := " "
+	if d == SQLMSSQL {
+		// The only datetime form SQL Server reads the same with every language setting
+		sep = "T"
+	}
+	switch typ {
+	case "DATE":
+		return t.Format("2006-01-02")
+	case "TIME":
+		return t.Format("15:04:05" + fraction)
+	case "DATETIME":
+		return t.Format("2006-01-02" + sep + "15:04:05.999")
+	case "SMALLDATETIME":
+		return t.Format("2006-01-02" + sep + "15:04:05")
+	case "DATETIMEOFFSET", "":
+		return t.Format("2006-01-02" + sep + "15:04:05" + fraction + "-07:00")
+	}
+	return t.Format("2006-01-02" + sep + "15:04:05" + fraction)
+}
+
+func (w *sqlWriter) boolLiteral(b bool) string {
+	switch {
+	case w.dialect == SQLPostgres || w.dialect == SQLMySQL:
+		return strings.ToUpper(strconv.FormatBool(b))
+	case b:
+		return "1"
+	}
+	return "0"
+}
+
+// binaryLiteral returns b as a hex literal of the dialect.
+func (w *sqlWriter) binaryLiteral(b []byte) string {
+	switch w.dialect {
+	case SQLMSSQL:
+		return "0x" + strings.ToUpper(hex.EncodeToString(b))
+	case SQLPostgres:
+		return `'\x` + hex.EncodeToString(b) + `'`
+	}
+	return "X'" + strings.ToUpper(hex.EncodeToString(b)) + "'"
+}
+
+// mysqlEscaper escapes MySQL string literals, in which backslash is an
+// escape character unless NO_BACKSLASH_ESCAPES is set.
+var mysqlEscaper = strings.NewReplacer(`\`, `\\`, `'`, `''`, "\x00", `\0`)
+
+// quoteString returns s as a string literal of the dialect. SQL Server gets
+// Unicode N'...' literals. PostgreSQL and SQLite text cannot hold NUL
+// characters, which fail the row.
+func (w *sqlWriter) quoteString(s string) (string, error) {
+	switch w.dialect {
+	case SQLMSSQL:
+		return "N'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+	case SQLMySQL:
+		return "'" + mysqlEscaper.Replace(s) + "'", nil
+	}
+	if strings.ContainsRune(s, 0) {
+		return "", i18n.Errorf("sql.nul_character", w.dialect)
+	}
+	// standard_conforming_strings is on since PostgreSQL 9.1: backslashes are literal
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+}
+
+// WriteBatch writes the rows as INSERT statements of up to
+// SQLOptions.InsertRows rows, in one transaction when SQLOptions.Transactions
+// is set.
+func (w *sqlWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	w.buf.WriteString("\n")
+	if w.opts.Transactions {
+		w.buf.WriteString(w.beginStatement())
+	}
+	for start := 0; start < len(rows); start += w.insertRows {
+		end := start + w.insertRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		w.buf.WriteString(w.insert)
+		for i, row := range rows[start:end] {
+			w.buf.WriteString("(")
+			for j, lit := range row {
+				if j > 0 {
+					w.buf.WriteString(", ")
+				}
+				w.buf.WriteString(lit.(string))
+			}
+			if start+i == end-1 {
+				w.buf.WriteString(");\n")
+			} else {
+				w.buf.WriteString("),\n")
+			}
+		}
+	}
+	if w.opts.Transactions {
+		w.buf.WriteString("COMMIT;\n")
+	}
+	if err := w.buf.Flush(); err != nil {
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+func (w *sqlWriter) beginStatement() string {
+	switch w.dialect {
+	case SQLMSSQL:
+		return "BEGIN TRANSACTION;\n"
+	case SQLMySQL:
+		return "START TRANSACTION;\n"
+	}
+	return "BEGIN;\n"
+}
+
+// NativeValues reports that the writer takes driver values and converts them
+// itself.
+func (w *sqlWriter) NativeValues() bool {
+	return true
+}
+
+// Close flushes and closes the file.
+func (w *sqlWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.file.Close()
+}
+
+// Location returns the output file name.
+func (w *sqlWriter) Location() string {
+	return w.filename
+}
+
+// BytesWritten returns the number of bytes written to the file.
+func (w *sqlWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
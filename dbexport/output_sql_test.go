@@ -0,0 +1,146 @@
+package dbexport
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runSQLExport exports typedColumns and typedRows with the sql format and
+// returns the script.
+func runSQLExport(t *testing.T, opts WriterOptions) string {
+	t.Helper()
+	opts.Table = "T"
+	opts.Output = filepath.Join(t.TempDir(), "t.sql")
+	rows := &staticRows{cols: columnNames(typedColumns), data: typedRows()}
+	res, err := runExport(context.Background(), rows, typedColumns, newSQLWriter(opts), exportRun{batchSize: 4})
+	if err != nil {
+		t.Fatalf("%s: export failed: %v", opts.SQL.Dialect, err)
+	}
+	b, err := os.ReadFile(res.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSQLWriter_Dialects(t *testing.T) {
+	tests := []struct {
+		dialect SQLDialect
+		want    []string
+	}{
+		{SQLMSSQL, []string{
+			"CREATE TABLE [t] (\n    [id] INT NOT NULL,\n    [tiny] TINYINT,\n    [price] DECIMAL(10,2),",
+			"INSERT INTO [t] ([id], [tiny], [price], [ok], [born], [at], [off], [name], [guid], [data], [ratio]) VALUES\n",
+			"(1, 50, 12.34, 0, N'2024-02-29', N'2024-02-29T13:45:10.1234567', N'2024-02-29T08:45:10-05:00', N'ñandú', N'6f9619ff-8b86-d011-b42d-00c04fc964ff', 0x0001, 0.5),\n",
+			"(6, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL);\n",
+		}},
+		{SQLPostgres, []string{
+			`"tiny" smallint,`, `"ok" boolean,`, `"off" timestamptz,`, `"guid" uuid,`, `"data" bytea,`, `"ratio" double precision`,
+			`(2, 100, 12.34, TRUE, '2024-02-29', '2024-02-29 13:45:10.1234567', '2024-02-29 08:45:10-05:00', 'ñandú', '6f9619ff-8b86-d011-b42d-00c04fc964ff', '\x0002', 0.5),`,
+		}},
+		{SQLMySQL, []string{
+			"`tiny` TINYINT UNSIGNED,", "`at` DATETIME(6),", "`guid` CHAR(36),", "`data` LONGBLOB,", ") CHARACTER SET utf8mb4;\n",
+			// datetimeoffset is stored in UTC
+			"(1, 50, 12.34, FALSE, '2024-02-29', '2024-02-29 13:45:10.123456', '2024-02-29 13:45:10', 'ñandú', '6f9619ff-8b86-d011-b42d-00c04fc964ff', X'0001', 0.5),",
+		}},
+	}
+	for _, tt := range tests {
+		got := runSQLExport(t, WriterOptions{SQL: SQLOptions{Dialect: tt.dialect}})
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: script lacks %q:\n%s", tt.dialect, want, got)
+			}
+		}
+		// One INSERT per batch of rows
+		if n := strings.Count(got, "INSERT INTO"); n != 2 {
+			t.Errorf("%s: %d INSERT statements, want 2", tt.dialect, n)
+		}
+	}
+}
+
+func TestSQLWriter_SQLite(t *testing.T) {
+	script := runSQLExport(t, WriterOptions{SQL: SQLOptions{Dialect: SQLSQLite, InsertRows: 3, Transactions: true}})
+	if n := strings.Count(script, "INSERT INTO"); n != 3 {
+		t.Errorf("%d INSERT statements, want 3", n)
+	}
+	if n := strings.Count(script, "BEGIN;\n"); n != 2 || strings.Count(script, "COMMIT;\n") != 2 {
+		t.Errorf("%d transactions, want 2:\n%s", n, script)
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(script); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, script)
+	}
+	var count int
+	var price float64
+	var name, guid string
+	var data []byte
+	err = db.QueryRow(`SELECT count(*), max(price), max(name), max(guid), max(data) FROM "t"`).Scan(&count, &price, &name, &guid, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 || price != 12.34 || name != "ñandú" || guid != "6f9619ff-8b86-d011-b42d-00c04fc964ff" || len(data) != 2 || data[1] != 5 {
+		t.Errorf("got %d rows, %v, %q, %q, %v", count, price, name, guid, data)
+	}
+}
+
+func TestSQLWriter_Literals(t *testing.T) {
+	cols := []Column{{Name: "s", DatabaseType: "NVARCHAR"}}
+	for _, tt := range []struct {
+		dialect SQLDialect
+		in      string
+		want    string
+	}{
+		{SQLMSSQL, `it's \ok`, `N'it''s \ok'`},
+		{SQLPostgres, `it's \ok`, `'it''s \ok'`},
+		{SQLMySQL, "it's \\ok\x00", `'it''s \\ok\0'`},
+		{SQLSQLite, "a\nb", "'a\nb'"},
+	} {
+		w := newSQLWriter(WriterOptions{SQL: SQLOptions{Dialect: tt.dialect}})
+		w.cols = cols
+		row := []interface{}{tt.in}
+		if err := w.prepareRow(row); err != nil || row[0] != tt.want {
+			t.Errorf("%s: %q = %v (%v), want %s", tt.dialect, tt.in, row[0], err, tt.want)
+		}
+	}
+
+	w := newSQLWriter(WriterOptions{SQL: SQLOptions{Dialect: SQLPostgres}})
+	w.cols = cols
+	if err := w.prepareRow([]interface{}{"a\x00"}); err == nil {
+		t.Error("expected an error for a NUL character in PostgreSQL")
+	}
+
+	// Text types write every value as a string
+	w = newSQLWriter(WriterOptions{Types: TypesText, SQL: SQLOptions{Dialect: SQLMSSQL}})
+	w.cols = []Column{{Name: "n", DatabaseType: "INT"}, {Name: "d", DatabaseType: "DATETIME"}}
+	row := []interface{}{int64(7), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := w.prepareRow(row); err != nil || row[0] != "N'7'" || row[1] != "N'2024-01-02T03:04:05.000'" {
+		t.Errorf("text row = %v (%v)", row, err)
+	}
+}
+
+func TestSQLOptions_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		opts SQLOptions
+		ok   bool
+	}{
+		{SQLOptions{}, true},
+		{SQLOptions{Dialect: "Postgres", InsertRows: 5000}, true},
+		{SQLOptions{Dialect: SQLMSSQL, InsertRows: 1001}, false},
+		{SQLOptions{InsertRows: -1}, false},
+		{SQLOptions{Dialect: "oracle"}, false},
+	} {
+		if err := tt.opts.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: got %v", tt.opts, err)
+		}
+	}
+}
@@ -2,140 +2,276 @@ package dbexport
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"getmssql/i18n"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 )
 
-// WriteSQLite writes table data to a SQLite3 database file.
-func WriteSQLite(rows Rows, columns []string, table string, now time.Time) error {
-	// Defensive checks for nil rows and columns
-	if rows == nil {
-		return fmt.Errorf("rows is nil")
+func init() {
+	RegisterFormat("sqlite3", func(opts WriterOptions) Writer {
+		return newSQLiteWriter(opts)
+	})
+}
+
+// sqliteWriter writes table data to a table in a SQLite3 database file,
+// output.sqlite3 unless WriterOptions.Output says otherwise.
+type sqliteWriter struct {
+	table     string
+	dbFile    string
+	openDB    func(string, string) (*sql.DB, error)
+	scanln    func(...interface{}) (int, error)
+	overwrite OverwritePolicy
+	types     TypeMode
+	conv      valueConverter
+	cols      []Column
+	inserter  batchInserter
+}
+
+func newSQLiteWriter(opts WriterOptions) *sqliteWriter {
+	dbFile := opts.Output
+	if dbFile == "" {
+		dbFile = "output.sqlite3"
 	}
-	if len(columns) == 0 {
-		return fmt.Errorf("columns is empty")
+	return &sqliteWriter{
+		table:     opts.Table,
+		dbFile:    dbFile,
+		overwrite: opts.Overwrite,
+		types:     opts.Types,
+		conv:      newTextConverter(opts),
+		openDB:    openSQLite,
+		scanln:    scanln,
 	}
-	return WriteSQLiteWithDeps(rows, columns, table, now)
 }
 
-// WriteSQLiteWithDeps writes table data to a SQLite3 database file (for testability).
-func WriteSQLiteWithDeps(rows Rows, cols []string, table string, start time.Time) error {
+// Open creates the target table, asking before dropping an existing one,
+// and starts the first transaction.
+func (w *sqliteWriter) Open(ctx context.Context, cols []Column) error {
+	if len(cols) == 0 {
+		return i18n.Errorf("writer.no_columns")
+	}
 	// Defensive: ensure all column names are non-empty
 	for i, col := range cols {
-		if col == "" {
-			return fmt.Errorf("column name at index %d is empty", i)
+		if col.Name == "" {
+			return i18n.Errorf("writer.empty_column", i)
 		}
 	}
-	// Set up context that cancels on SIGINT (Ctrl-C)
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-	// Defensive checks for nil rows and columns
-	if rows == nil {
-		return fmt.Errorf("rows is nil")
-	}
-	if len(cols) == 0 {
-		return fmt.Errorf("columns is empty")
-	}
-	dbFile := "output.sqlite3"
-	tableLower := strings.ToLower(table)
-	sqliteDB, err := openSQLite("sqlite3", dbFile)
+	tableLower := strings.ToLower(w.table)
+	sqliteDB, err := w.openDB("sqlite3", w.dbFile)
 	if err != nil {
-		return fmt.Errorf("error opening SQLite3 database: %w", err)
+		return i18n.Errorf("db.open_error", "SQLite3", err)
 	}
 	if sqliteDB == nil {
 		return fmt.Errorf("openSQLite returned nil *sql.DB without error")
 	}
-	defer sqliteDB.Close()
 
 	// Check if table exists
 	var tableExists int
-	err = sqliteDB.QueryRow(fmt.Sprintf("SELECT count(*) FROM sqlite_master WHERE type='table' AND name='%s'", tableLower)).Scan(&tableExists)
+	err = sqliteDB.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM sqlite_master WHERE type='table' AND name='%s'", tableLower)).Scan(&tableExists)
 	if err != nil {
-		return fmt.Errorf("error checking if table exists in SQLite3: %w", err)
+		sqliteDB.Close()
+		return i18n.Errorf("db.exists_check_error", "SQLite3", err)
 	}
 	if tableExists > 0 {
-		fmt.Printf("Table '%s' already exists in %s. Delete and recreate? (y/N): ", tableLower, dbFile)
-		var response string
-		scanln(&response)
-		if strings.ToLower(strings.TrimSpace(response)) == "y" {
-			dropStmt := fmt.Sprintf("DROP TABLE IF EXISTS [%s]", tableLower)
-			if _, err := sqliteDB.Exec(dropStmt); err != nil {
-				return fmt.Errorf("error dropping table in SQLite3: %w", err)
-			}
-			fmt.Printf("Table '%s' dropped.\n", tableLower)
-		} else {
-			fmt.Println("Aborted by user.")
-			return nil
+		exists := i18n.T("db.table_exists", tableLower, w.dbFile)
+		if err := confirmOverwrite(w.overwrite, exists, i18n.T("db.recreate"), w.scanln); err != nil {
+			sqliteDB.Close()
+			return err
+		}
+		dropStmt := fmt.Sprintf("DROP TABLE IF EXISTS [%s]", tableLower)
+		if _, err := sqliteDB.ExecContext(ctx, dropStmt); err != nil {
+			sqliteDB.Close()
+			return i18n.Errorf("db.drop_error", "SQLite3", err)
 		}
+		fmt.Println(i18n.T("db.table_dropped", tableLower))
 	}
 
 	// Create table
 	colDefs := make([]string, len(cols))
 	for i, col := range cols {
-		colDefs[i] = fmt.Sprintf("[%s] TEXT", col)
+		colDefs[i] = fmt.Sprintf("[%s] %s", col.Name, sqliteColumnDef(col, w.types))
 	}
 	createStmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS [%s] (%s)", tableLower, strings.Join(colDefs, ", "))
-	if _, err := sqliteDB.Exec(createStmt); err != nil {
-		return fmt.Errorf("error creating table in SQLite3: %w", err)
+	if _, err := sqliteDB.ExecContext(ctx, createStmt); err != nil {
+		sqliteDB.Close()
+		return i18n.Errorf("db.create_error", "SQLite3", err)
 	}
 	// Quote column names for safety
 	quotedCols := make([]string, len(cols))
 	for i, col := range cols {
-		quotedCols[i] = fmt.Sprintf("[%s]", col)
+		quotedCols[i] = fmt.Sprintf("[%s]", col.Name)
 	}
 	insertStmt := fmt.Sprintf("INSERT INTO [%s] (%s) VALUES (%s)", tableLower, strings.Join(quotedCols, ", "), strings.TrimRight(strings.Repeat("?,", len(cols)), ","))
-	batchSize := 10000
-	rowCount := 0
-	tx, err := sqliteDB.Begin()
-	if err != nil {
-		return fmt.Errorf("error starting SQLite3 transaction: %w", err)
+	w.cols = cols
+	w.inserter = batchInserter{db: sqliteDB, engine: "SQLite3", query: insertStmt}
+	if err := w.inserter.begin(ctx); err != nil {
+		sqliteDB.Close()
+		return err
 	}
-	stmt, err := tx.Prepare(insertStmt)
-	if err != nil {
-		return fmt.Errorf("error preparing SQLite3 statement: %w", err)
-	}
-	defer stmt.Close()
-	for rows.Next() {
-		select {
-		case <-ctx.Done():
-			fmt.Println("\nAborted by user (Ctrl-C)")
-			return fmt.Errorf("aborted by user (Ctrl-C)")
-		default:
+	return nil
+}
+
+// sqliteColumnDef returns the declared type of col in the CREATE TABLE
+// statement: the SQLite affinity matching its MSSQL type, plus NOT NULL for
+// non-nullable columns. With TypesText every column is plain TEXT.
+func sqliteColumnDef(col Column, mode TypeMode) string {
+	if mode == TypesText {
+		return "TEXT"
+	}
+	def := sqliteAffinity(col)
+	if !col.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// sqliteExactDigits is the number of significant digits SQLite keeps when it
+// stores decimal text in a NUMERIC column (as INTEGER or REAL).
+const sqliteExactDigits = 15
+
+// sqliteAffinity maps an MSSQL column, as reported by the driver, to a SQLite
+// type. Dates and timestamps get the DATE, DATETIME and TIMESTAMP declared
+// types that SQLite drivers read back as times; their values are stored as
+// ISO 8601 text, which SQLite's date and time functions accept. Decimals
+// wider than SQLite can hold exactly are stored as TEXT to keep every digit.
+func sqliteAffinity(col Column) string {
+	typ := strings.ToUpper(col.DatabaseType)
+	if isDecimalType(typ) {
+		if decimalPrecision(col) > sqliteExactDigits {
+			return "TEXT"
+		}
+		return "NUMERIC"
+	}
+	if isBinaryType(typ) || isSpatialType(typ) {
+		return "BLOB"
+	}
+	switch typ {
+	case "BIGINT", "INT", "SMALLINT", "TINYINT", "BIT":
+		return "INTEGER"
+	case "FLOAT", "REAL":
+		return "REAL"
+	case "DATE":
+		return "DATE"
+	case "DATETIME", "DATETIME2", "SMALLDATETIME":
+		return "DATETIME"
+	case "DATETIMEOFFSET":
+		return "TIMESTAMP"
+	}
+	return "TEXT"
+}
+
+// sqliteValue prepares a driver value for a column declared as
+// sqliteAffinity(col). TEXT columns get the values conv produces for the
+// file formats.
+func sqliteValue(conv valueConverter, col Column, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch sqliteAffinity(col) {
+	case "TEXT":
+		return conv.convert(col, v)
+	case "NUMERIC":
+		// DECIMAL and MONEY arrive as their exact decimal text
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
 		}
-		vals := ScanRowValues(rows, cols)
-		if _, err := stmt.Exec(vals...); err != nil {
-			return fmt.Errorf("error inserting row into SQLite3: %w", err)
+	case "DATE":
+		if t, ok := v.(time.Time); ok {
+			return t.Format("2006-01-02"), nil
 		}
-		rowCount++
-		if rowCount%batchSize == 0 {
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("error committing SQLite3 transaction: %w", err)
-			}
-			tx, err = sqliteDB.Begin()
-			if err != nil {
-				return fmt.Errorf("error starting SQLite3 transaction: %w", err)
-			}
-			stmt, err = tx.Prepare(insertStmt)
-			if err != nil {
-				return fmt.Errorf("error preparing SQLite3 statement: %w", err)
-			}
-			fmt.Printf("\rDownloaded %d rows...", rowCount)
-		} else if rowCount%1000 == 0 {
-			fmt.Printf("\rDownloaded %d rows...", rowCount)
+	case "DATETIME":
+		if t, ok := v.(time.Time); ok {
+			return t.Format("2006-01-02 15:04:05.9999999"), nil
+		}
+	case "TIMESTAMP":
+		if t, ok := v.(time.Time); ok {
+			return t.Format("2006-01-02 15:04:05.9999999-07:00"), nil
 		}
 	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("row error: %w", err)
+	return v, nil
+}
+
+// prepareRow converts a row of driver values for insertion, see sqliteValue.
+// With TypesText every value is converted to its text form.
+func (w *sqliteWriter) prepareRow(row []interface{}) error {
+	if w.types == TypesText {
+		return w.conv.convertRow(w.cols, row)
 	}
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing SQLite3 transaction: %w", err)
+	for i, v := range row {
+		val, err := sqliteValue(w.conv, w.cols[i], v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", w.cols[i].Name, err)
+		}
+		row[i] = val
 	}
-	fmt.Printf("\rTotal rows downloaded: %d\n", rowCount)
-	elapsed := time.Since(start)
-	fmt.Printf("Table '%s' data written to %s (table: %s) in %s\n", table, dbFile, table, elapsed)
 	return nil
 }
+
+// WriteBatch inserts the rows and commits them as one transaction.
+func (w *sqliteWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	return w.inserter.insert(ctx, rows)
+}
+
+// NativeValues reports that the writer takes driver values: it converts them
+// itself, also when every column is TEXT.
+func (w *sqliteWriter) NativeValues() bool {
+	return true
+}
+
+// storesWKB reports that geography and geometry values are stored as WKB
+// BLOBs, unless every column is TEXT.
+func (w *sqliteWriter) storesWKB() bool {
+	return w.types != TypesText
+}
+
+// Close commits any pending transaction and closes the database.
+func (w *sqliteWriter) Close() error {
+	return w.inserter.close()
+}
+
+// BytesWritten returns the size of the database file.
+func (w *sqliteWriter) BytesWritten() int64 {
+	info, err := os.Stat(w.dbFile)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Location returns the database file and table written to.
+func (w *sqliteWriter) Location() string {
+	return fmt.Sprintf("%s (table: %s)", w.dbFile, w.table)
+}
+
+// WriteSQLite writes table data to a SQLite3 database file.
+func WriteSQLite(rows Rows, columns []string, table string, now time.Time) error {
+	// Defensive checks for nil rows and columns
+	if rows == nil {
+		return fmt.Errorf("rows is nil")
+	}
+	if len(columns) == 0 {
+		return i18n.Errorf("writer.no_columns")
+	}
+	return WriteSQLiteWithDeps(rows, columns, table, now)
+}
+
+// WriteSQLiteWithDeps writes table data to a SQLite3 database file (for testability).
+func WriteSQLiteWithDeps(rows Rows, cols []string, table string, start time.Time) error {
+	// Defensive: ensure all column names are non-empty
+	for i, col := range cols {
+		if col == "" {
+			return i18n.Errorf("writer.empty_column", i)
+		}
+	}
+	// Defensive checks for nil rows and columns
+	if rows == nil {
+		return fmt.Errorf("rows is nil")
+	}
+	if len(cols) == 0 {
+		return i18n.Errorf("writer.no_columns")
+	}
+	w := newSQLiteWriter(WriterOptions{Table: table})
+	return runLegacyExport(rows, cols, table, w, start)
+}
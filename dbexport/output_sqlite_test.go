@@ -1,6 +1,7 @@
 package dbexport
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -593,3 +594,217 @@ func TestWriteSQLiteWithDeps_BatchPrepareError(t *testing.T) {
 		t.Errorf("expected batch prepare error, got: %v", err)
 	}
 }
+
+func TestSQLiteColumnDef(t *testing.T) {
+	cases := []struct {
+		col  Column
+		mode TypeMode
+		want string
+	}{
+		{Column{DatabaseType: "INT", Nullable: false}, TypesNative, "INTEGER NOT NULL"},
+		{Column{DatabaseType: "BIGINT", Nullable: true}, TypesNative, "INTEGER"},
+		{Column{DatabaseType: "BIT", Nullable: true}, "", "INTEGER"},
+		{Column{DatabaseType: "FLOAT", Nullable: true}, TypesNative, "REAL"},
+		{Column{DatabaseType: "DECIMAL", Nullable: true}, TypesNative, "NUMERIC"},
+		{Column{DatabaseType: "DECIMAL", Precision: 38, Scale: 10, Nullable: true}, TypesNative, "TEXT"},
+		{Column{DatabaseType: "MONEY", Nullable: false}, TypesNative, "TEXT NOT NULL"},
+		{Column{DatabaseType: "SMALLMONEY", Nullable: true}, TypesNative, "NUMERIC"},
+		{Column{DatabaseType: "VARBINARY", Nullable: true}, TypesNative, "BLOB"},
+		{Column{DatabaseType: "NVARCHAR", Nullable: true}, TypesNative, "TEXT"},
+		{Column{DatabaseType: "DATETIME2", Nullable: true}, TypesNative, "DATETIME"},
+		{Column{DatabaseType: "DATETIMEOFFSET"}, TypesNative, "TIMESTAMP NOT NULL"},
+		{Column{DatabaseType: "DATE", Nullable: true}, TypesNative, "DATE"},
+		{Column{DatabaseType: "TIME", Nullable: true}, TypesNative, "TEXT"},
+		{Column{Nullable: true}, TypesNative, "TEXT"},
+		{Column{DatabaseType: "INT", Nullable: false}, TypesText, "TEXT"},
+	}
+	for _, c := range cases {
+		if got := sqliteColumnDef(c.col, c.mode); got != c.want {
+			t.Errorf("sqliteColumnDef(%+v, %q) = %q, want %q", c.col, c.mode, got, c.want)
+		}
+	}
+}
+
+func TestSQLiteWriter_TypedColumns(t *testing.T) {
+	for _, mode := range []TypeMode{TypesNative, TypesText} {
+		t.Run(string(mode), func(t *testing.T) {
+			dbFile := t.TempDir() + "/typed.sqlite3"
+			w := newSQLiteWriter(WriterOptions{Table: "Orders", Output: dbFile, Types: mode})
+			cols := []Column{
+				{Name: "id", DatabaseType: "INT"},
+				{Name: "total", DatabaseType: "DECIMAL", Nullable: true},
+				{Name: "note", DatabaseType: "NVARCHAR", Nullable: true},
+			}
+			rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{int64(1), []byte("9.50"), "a"}, {int64(2), nil, nil}}}
+			res, err := runExport(context.Background(), rows, cols, w, exportRun{})
+			if err != nil || res.RowsWritten != 2 {
+				t.Fatalf("export failed: %v (%+v)", err, res)
+			}
+
+			db, err := sql.Open("sqlite3", dbFile)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer db.Close()
+			info, err := db.Query("SELECT name, type, \"notnull\" FROM pragma_table_info('orders')")
+			if err != nil {
+				t.Fatalf("table info: %v", err)
+			}
+			defer info.Close()
+			var got []string
+			for info.Next() {
+				var name, typ string
+				var notNull int
+				if err := info.Scan(&name, &typ, &notNull); err != nil {
+					t.Fatalf("scan: %v", err)
+				}
+				got = append(got, fmt.Sprintf("%s %s %d", name, typ, notNull))
+			}
+			want := "id INTEGER 1,total NUMERIC 0,note TEXT 0"
+			if mode == TypesText {
+				want = "id TEXT 0,total TEXT 0,note TEXT 0"
+			}
+			if strings.Join(got, ",") != want {
+				t.Errorf("unexpected columns: %v, want %s", got, want)
+			}
+			var sum float64
+			if err := db.QueryRow("SELECT sum(total) + sum(id) FROM orders").Scan(&sum); err != nil || sum != 12.5 {
+				t.Errorf("expected numeric arithmetic to work, got %v (err: %v)", sum, err)
+			}
+		})
+	}
+}
+
+func TestSQLiteWriter_Timestamps(t *testing.T) {
+	dbFile := t.TempDir() + "/times.sqlite3"
+	w := newSQLiteWriter(WriterOptions{Table: "events", Output: dbFile})
+	cols := []Column{
+		{Name: "day", DatabaseType: "DATE", Nullable: true},
+		{Name: "at", DatabaseType: "DATETIME2", Nullable: true},
+		{Name: "at_tz", DatabaseType: "DATETIMEOFFSET", Nullable: true},
+	}
+	at := time.Date(2024, 2, 29, 13, 45, 10, 123456700, time.UTC)
+	atTZ := time.Date(2024, 2, 29, 13, 45, 10, 0, time.FixedZone("", -5*3600))
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{at, at, atTZ}}}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var dayText, atText, atTZText, atSQL, atTZUTC string
+	err = db.QueryRow(`SELECT CAST(day AS TEXT), CAST(at AS TEXT), CAST(at_tz AS TEXT), strftime('%Y-%m-%d %H:%M:%f', at), datetime(at_tz) FROM events`).
+		Scan(&dayText, &atText, &atTZText, &atSQL, &atTZUTC)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if dayText != "2024-02-29" || atText != "2024-02-29 13:45:10.1234567" || atTZText != "2024-02-29 13:45:10-05:00" {
+		t.Errorf("unexpected stored values: %q %q %q", dayText, atText, atTZText)
+	}
+	if atSQL != "2024-02-29 13:45:10.123" || atTZUTC != "2024-02-29 18:45:10" {
+		t.Errorf("expected SQLite date functions to read the values, got %q %q", atSQL, atTZUTC)
+	}
+	// The driver reads the declared types back as times
+	var gotAt, gotTZ time.Time
+	if err := db.QueryRow(`SELECT at, at_tz FROM events`).Scan(&gotAt, &gotTZ); err != nil {
+		t.Fatalf("scan times: %v", err)
+	}
+	if !gotAt.Equal(at) || !gotTZ.Equal(atTZ) {
+		t.Errorf("unexpected times: %v %v", gotAt, gotTZ)
+	}
+}
+
+func TestSQLiteWriter_ExactDecimals(t *testing.T) {
+	dbFile := t.TempDir() + "/decimals.sqlite3"
+	w := newSQLiteWriter(WriterOptions{Table: "ledger", Output: dbFile})
+	cols := []Column{
+		{Name: "wide", DatabaseType: "DECIMAL", Precision: 38, Scale: 10, Nullable: true},
+		{Name: "narrow", DatabaseType: "DECIMAL", Precision: 9, Scale: 2, Nullable: true},
+		{Name: "fee", DatabaseType: "MONEY", Nullable: true},
+	}
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{[]byte("1234567890123456789012345678.1234567890"), []byte("1234567.89"), []byte("922337203685477.5807")}}}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var wide, fee, narrowType string
+	var narrow float64
+	err = db.QueryRow(`SELECT wide, narrow, typeof(narrow), fee FROM ledger`).Scan(&wide, &narrow, &narrowType, &fee)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if wide != "1234567890123456789012345678.1234567890" || fee != "922337203685477.5807" {
+		t.Errorf("expected exact digits, got %q and %q", wide, fee)
+	}
+	if narrow != 1234567.89 || narrowType != "real" {
+		t.Errorf("expected narrow decimal stored as a number, got %v (%s)", narrow, narrowType)
+	}
+}
+
+func TestSQLiteWriter_UUIDs(t *testing.T) {
+	dbFile := t.TempDir() + "/uuids.sqlite3"
+	w := newSQLiteWriter(WriterOptions{Table: "items", Output: dbFile})
+	cols := []Column{{Name: "id", DatabaseType: "UNIQUEIDENTIFIER", Nullable: true}}
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{guidBytes}, {nil}}}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var id sql.NullString
+	if err := db.QueryRow(`SELECT id FROM items WHERE rowid = 1`).Scan(&id); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if id.String != "6f9619ff-8b86-d011-b42d-00c04fc964ff" {
+		t.Errorf("got %q, want canonical UUID text", id.String)
+	}
+}
+
+func TestSQLiteWriter_Blobs(t *testing.T) {
+	for mode, want := range map[TypeMode]string{TypesNative: "blob", TypesText: "text"} {
+		dbFile := t.TempDir() + "/blobs.sqlite3"
+		w := newSQLiteWriter(WriterOptions{Table: "files", Output: dbFile, Types: mode})
+		cols := []Column{{Name: "data", DatabaseType: "VARBINARY", Nullable: true}}
+		rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{[]byte("123")}}}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+			t.Fatalf("%s: export failed: %v", mode, err)
+		}
+		db, err := sql.Open("sqlite3", dbFile)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		var typ, hexData string
+		err = db.QueryRow(`SELECT typeof(data), CASE typeof(data) WHEN 'blob' THEN lower(hex(data)) ELSE data END FROM files`).Scan(&typ, &hexData)
+		db.Close()
+		if err != nil {
+			t.Fatalf("%s: query: %v", mode, err)
+		}
+		if typ != want || hexData != "313233" {
+			t.Errorf("%s: got %s %q, want %s \"313233\"", mode, typ, hexData, want)
+		}
+	}
+}
+
+func TestSQLiteWriter_SpatialWKB(t *testing.T) {
+	w := newSQLiteWriter(WriterOptions{Table: "t"})
+	if spatialEncodingFor(w) != spatialWKB {
+		t.Error("expected WKB for native types")
+	}
+	if got := sqliteColumnDef(Column{DatabaseType: "GEOGRAPHY", Nullable: true}, TypesNative); got != "BLOB" {
+		t.Errorf("got %s, want BLOB", got)
+	}
+	w = newSQLiteWriter(WriterOptions{Table: "t", Types: TypesText})
+	if spatialEncodingFor(w) != spatialWKT {
+		t.Error("expected WKT for text types")
+	}
+}
@@ -0,0 +1,347 @@
+package dbexport
+
+import (
+	"context"
+	"fmt"
+	"getmssql/i18n"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func init() {
+	RegisterFormat("xlsx", func(opts WriterOptions) Writer {
+		return newXLSXWriter(opts)
+	})
+}
+
+// xlsxMaxRows is the number of rows of a worksheet, header included. Tables
+// with more rows continue on further sheets.
+var xlsxMaxRows = excelize.TotalRows
+
+// xlsxExactDigits is the number of significant digits Excel keeps in a
+// number. Wider integers and decimals are written as text.
+const xlsxExactDigits = 15
+
+// Cell kinds of xlsxCellKind.
+const (
+	xlsxText     = "text"
+	xlsxNumber   = "number"
+	xlsxDecimal  = "decimal"
+	xlsxBool     = "bool"
+	xlsxDate     = "date"
+	xlsxDatetime = "datetime"
+	xlsxTime     = "time"
+)
+
+// xlsxNumberFormats are the number formats of the date and time cell kinds.
+var xlsxNumberFormats = map[string]string{
+	xlsxDate:     "yyyy-mm-dd",
+	xlsxDatetime: "yyyy-mm-dd hh:mm:ss",
+	xlsxTime:     "hh:mm:ss",
+}
+
+// xlsxWriter writes table data to an Excel workbook, streaming the rows into
+// worksheets named after the table with a bold, frozen header row. By
+// default the file is named after the table.
+type xlsxWriter struct {
+	table     string
+	filename  string
+	overwrite OverwritePolicy
+	scanln    func(...interface{}) (int, error)
+	types     TypeMode
+	timeZone  *time.Location
+	conv      valueConverter // text cells
+	file      *os.File
+	out       *countingWriter
+	book      *excelize.File
+	sheet     *excelize.StreamWriter
+	sheets    int
+	row       int // last row written on the current sheet
+	cols      []Column
+	kinds     []string
+	styles    []int // number format style of each column, 0 for none
+	header    []interface{}
+	bold      int
+}
+
+func newXLSXWriter(opts WriterOptions) *xlsxWriter {
+	filename := opts.Output
+	if filename == "" {
+		filename = fmt.Sprintf("%s.xlsx", strings.ToLower(opts.Table))
+	}
+	return &xlsxWriter{
+		table:     opts.Table,
+		filename:  filename,
+		overwrite: opts.Overwrite,
+		scanln:    scanln,
+		types:     opts.Types,
+		timeZone:  opts.TimeZone,
+		conv:      newTextConverter(opts),
+	}
+}
+
+// xlsxCellKind returns how the values of col are written: as numbers,
+// booleans, dates and times with a number format, or text. With TypesText
+// every column is text.
+func xlsxCellKind(col Column, mode TypeMode) string {
+	if mode == TypesText {
+		return xlsxText
+	}
+	switch typ := strings.ToUpper(col.DatabaseType); {
+	case typ == "BIT":
+		return xlsxBool
+	case typ == "TINYINT", typ == "SMALLINT", typ == "INT", typ == "BIGINT", typ == "REAL", typ == "FLOAT":
+		return xlsxNumber
+	case isDecimalType(typ):
+		return xlsxDecimal
+	case typ == "DATE":
+		return xlsxDate
+	case typ == "DATETIME2", typ == "DATETIME", typ == "SMALLDATETIME", typ == "DATETIMEOFFSET":
+		return xlsxDatetime
+	case typ == "TIME":
+		return xlsxTime
+	}
+	return xlsxText
+}
+
+// Open creates the output file and starts the first worksheet.
+func (w *xlsxWriter) Open(ctx context.Context, cols []Column) error {
+	file, err := createFile(w.filename, w.overwrite, w.scanln)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.out = &countingWriter{w: file}
+	w.book = excelize.NewFile()
+	w.cols = cols
+	w.kinds = make([]string, len(cols))
+	w.styles = make([]int, len(cols))
+	w.header = make([]interface{}, len(cols))
+	if err = w.setStyles(); err == nil {
+		err = w.nextSheet()
+	}
+	if err != nil {
+		w.book.Close()
+		file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return nil
+}
+
+// setStyles registers the header style and the number formats of the columns.
+func (w *xlsxWriter) setStyles() error {
+	var err error
+	if w.bold, err = w.book.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return err
+	}
+	formats := make(map[string]int)
+	for i, col := range w.cols {
+		w.header[i] = col.Name
+		w.kinds[i] = xlsxCellKind(col, w.types)
+		numFmt, ok := xlsxNumberFormats[w.kinds[i]]
+		if !ok {
+			continue
+		}
+		if _, ok := formats[numFmt]; !ok {
+			if formats[numFmt], err = w.book.NewStyle(&excelize.Style{CustomNumFmt: &numFmt}); err != nil {
+				return err
+			}
+		}
+		w.styles[i] = formats[numFmt]
+	}
+	return nil
+}
+
+// nextSheet finishes the current worksheet, if any, and starts the next one
+// with the header row.
+func (w *xlsxWriter) nextSheet() error {
+	if w.sheet != nil {
+		if err := w.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+	w.sheets++
+	name := xlsxSheetName(w.table, w.sheets)
+	var err error
+	if w.sheets == 1 {
+		// A new workbook has one empty sheet
+		err = w.book.SetSheetName(w.book.GetSheetName(0), name)
+	} else {
+		_, err = w.book.NewSheet(name)
+	}
+	if err != nil {
+		return err
+	}
+	if w.sheet, err = w.book.NewStreamWriter(name); err != nil {
+		return err
+	}
+	if err := w.sheet.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	w.row = 1
+	return w.sheet.SetRow("A1", w.header, excelize.RowOpts{StyleID: w.bold})
+}
+
+// xlsxSheetName returns the name of the nth worksheet of table: the table
+// name, followed by " (n)" from the second sheet on, without the characters
+// Excel does not allow and within its 31 character limit.
+func xlsxSheetName(table string, n int) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.Trim(table, "'"))
+	if name == "" {
+		name = "Sheet"
+	}
+	suffix := ""
+	if n > 1 {
+		suffix = fmt.Sprintf(" (%d)", n)
+	}
+	if max := excelize.MaxSheetNameLength - len(suffix); utf8.RuneCountInString(name) > max {
+		name = string([]rune(name)[:max])
+	}
+	return name + suffix
+}
+
+// prepareRow converts a row of driver values to cell values, see xlsxValue.
+func (w *xlsxWriter) prepareRow(row []interface{}) error {
+	for i, v := range row {
+		val, err := w.xlsxValue(i, v)
+		if err != nil {
+			return i18n.Errorf("values.column_error", w.cols[i].Name, err)
+		}
+		row[i] = val
+	}
+	return nil
+}
+
+// xlsxValue converts the driver value of column i to a cell value: a number,
+// a bool, a time.Time, a fraction of a day for times of day, or text. Values
+// Excel cannot hold exactly, such as numbers of more than 15 digits and
+// dates before 1900, are written as text.
+func (w *xlsxWriter) xlsxValue(i int, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	col := w.cols[i]
+	switch x := v.(type) {
+	case bool:
+		if w.kinds[i] == xlsxBool {
+			return x, nil
+		}
+	case int64:
+		if w.kinds[i] == xlsxNumber && x > -1e15 && x < 1e15 {
+			return x, nil
+		}
+	case float64, float32:
+		if w.kinds[i] == xlsxNumber {
+			return x, nil
+		}
+	case []byte:
+		if w.kinds[i] == xlsxDecimal && significantDigits(string(x)) <= xlsxExactDigits {
+			if f, err := strconv.ParseFloat(string(x), 64); err == nil {
+				return f, nil
+			}
+		}
+	case time.Time:
+		switch w.kinds[i] {
+		case xlsxTime:
+			midnight := time.Date(x.Year(), x.Month(), x.Day(), 0, 0, 0, 0, x.Location())
+			return x.Sub(midnight).Hours() / 24, nil
+		case xlsxDate, xlsxDatetime:
+			if w.timeZone != nil && strings.EqualFold(col.DatabaseType, "DATETIMEOFFSET") {
+				x = x.In(w.timeZone)
+			}
+			if x.Year() >= 1900 {
+				// Excel has no time zones: keep the wall clock
+				return time.Date(x.Year(), x.Month(), x.Day(), x.Hour(), x.Minute(), x.Second(), x.Nanosecond(), time.UTC), nil
+			}
+		}
+	}
+	text, err := w.conv.convert(col, v)
+	if err != nil {
+		return nil, err
+	}
+	s := formatDelimitedValues([]interface{}{text}, "")[0]
+	if utf8.RuneCountInString(s) > excelize.TotalCellChars {
+		return nil, i18n.Errorf("xlsx.cell_too_long", excelize.TotalCellChars)
+	}
+	return s, nil
+}
+
+// significantDigits counts the significant digits of decimal text.
+func significantDigits(s string) int {
+	s = strings.TrimLeft(strings.TrimLeft(s, "+-"), "0.")
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		s = s[:i]
+	}
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+	}
+	return len(strings.Replace(s, ".", "", 1))
+}
+
+// WriteBatch appends the rows to the current worksheet, starting a new one
+// when it is full.
+func (w *xlsxWriter) WriteBatch(ctx context.Context, rows [][]interface{}) error {
+	for _, vals := range rows {
+		if w.row == xlsxMaxRows {
+			if err := w.nextSheet(); err != nil {
+				return i18n.Errorf("file.write_error", err)
+			}
+		}
+		w.row++
+		cells := make([]interface{}, len(vals))
+		for i, v := range vals {
+			cells[i] = v
+			if _, isTime := v.(time.Time); (isTime || w.kinds[i] == xlsxTime) && w.styles[i] != 0 {
+				cells[i] = excelize.Cell{StyleID: w.styles[i], Value: v}
+			}
+		}
+		if err := w.sheet.SetRow(fmt.Sprintf("A%d", w.row), cells); err != nil {
+			return i18n.Errorf("file.write_error", err)
+		}
+	}
+	return nil
+}
+
+// NativeValues reports that the writer takes driver values and converts them
+// itself.
+func (w *xlsxWriter) NativeValues() bool {
+	return true
+}
+
+// Close finishes the last worksheet, writes the workbook and closes the file.
+func (w *xlsxWriter) Close() error {
+	err := w.sheet.Flush()
+	if err == nil {
+		err = w.book.Write(w.out)
+	}
+	w.book.Close()
+	if err != nil {
+		w.file.Close()
+		return i18n.Errorf("file.write_error", err)
+	}
+	return w.file.Close()
+}
+
+// Location returns the output file name.
+func (w *xlsxWriter) Location() string {
+	return w.filename
+}
+
+// BytesWritten returns the number of bytes written to the file, which is
+// only written when the export closes.
+func (w *xlsxWriter) BytesWritten() int64 {
+	if w.out == nil {
+		return 0
+	}
+	return w.out.n
+}
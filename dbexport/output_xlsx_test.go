@@ -0,0 +1,154 @@
+package dbexport
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXLSXWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := newXLSXWriter(WriterOptions{Table: "T"})
+	w.filename = filepath.Join(dir, w.filename)
+	rows := &staticRows{cols: columnNames(typedColumns), data: typedRows()}
+	res, err := runExport(context.Background(), rows, typedColumns, w, exportRun{batchSize: 4})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if filepath.Base(res.Output) != "t.xlsx" {
+		t.Errorf("file %s, want t.xlsx", filepath.Base(res.Output))
+	}
+
+	f, err := excelize.OpenFile(res.Output)
+	if err != nil {
+		t.Fatalf("not an XLSX file: %v", err)
+	}
+	defer f.Close()
+	if got := f.GetSheetList(); len(got) != 1 || got[0] != "T" {
+		t.Fatalf("sheets %v, want [T]", got)
+	}
+	header, _ := f.GetRows("T")
+	if len(header) != 7 || strings.Join(header[0], ",") != "id,tiny,price,ok,born,at,off,name,guid,data,ratio" {
+		t.Fatalf("got %d rows, header %v", len(header), header[0])
+	}
+	style, _ := f.GetCellStyle("T", "A1")
+	if s, _ := f.GetStyle(style); s == nil || s.Font == nil || !s.Font.Bold {
+		t.Error("header is not bold")
+	}
+	if panes, _ := f.GetPanes("T"); !panes.Freeze || panes.YSplit != 1 {
+		t.Errorf("panes %+v, want the first row frozen", panes)
+	}
+
+	raw := excelize.Options{RawCellValue: true}
+	for cell, want := range map[string]string{
+		"A2": "1",
+		"B3": "100",
+		"C2": "12.34",
+		"D3": "1",
+		"E2": "45351",
+		"F2": "45351.573033",
+		"G2": "45351.364699",
+		"H2": "ñandú",
+		"I2": "6f9619ff-8b86-d011-b42d-00c04fc964ff",
+		"J2": "0001",
+		"K2": "0.5",
+	} {
+		got, err := f.GetCellValue("T", cell, raw)
+		if err != nil || !strings.HasPrefix(got, want) {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+	}
+	for cell, want := range map[string]excelize.CellType{"A2": excelize.CellTypeUnset, "C2": excelize.CellTypeUnset, "D2": excelize.CellTypeBool, "H2": excelize.CellTypeInlineString} {
+		if got, _ := f.GetCellType("T", cell); got != want {
+			t.Errorf("%s has type %v, want %v", cell, got, want)
+		}
+	}
+	// Formatted values show the number formats of dates
+	if got, _ := f.GetCellValue("T", "E2"); got != "2024-02-29" {
+		t.Errorf("born = %q, want 2024-02-29", got)
+	}
+	if got, _ := f.GetCellValue("T", "F2"); got != "2024-02-29 13:45:10" {
+		t.Errorf("at = %q, want 2024-02-29 13:45:10", got)
+	}
+	if got, _ := f.GetCellValue("T", "B7"); got != "" {
+		t.Errorf("NULL tiny = %q, want an empty cell", got)
+	}
+}
+
+func TestXLSXWriter_Values(t *testing.T) {
+	cols := []Column{
+		{Name: "big", DatabaseType: "BIGINT"},
+		{Name: "amount", DatabaseType: "DECIMAL", Precision: 38, Scale: 2},
+		{Name: "old", DatabaseType: "DATETIME2"},
+		{Name: "clock", DatabaseType: "TIME"},
+	}
+	w := newXLSXWriter(WriterOptions{Table: "T"})
+	w.cols = cols
+	w.kinds = []string{xlsxNumber, xlsxDecimal, xlsxDatetime, xlsxTime}
+	row := []interface{}{
+		int64(1234567890123456789),
+		[]byte("123456789012345678.25"),
+		time.Date(1850, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(1, 1, 1, 18, 0, 0, 0, time.UTC),
+	}
+	if err := w.prepareRow(row); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"1234567890123456789", "123456789012345678.25", "1850-01-02T03:04:05.0000000", 0.75}
+	for i := range want {
+		if row[i] != want[i] {
+			t.Errorf("%s = %#v, want %#v", cols[i].Name, row[i], want[i])
+		}
+	}
+
+	w.cols = []Column{{Name: "note", DatabaseType: "NVARCHAR"}}
+	w.kinds = []string{xlsxText}
+	err := w.prepareRow([]interface{}{strings.Repeat("x", excelize.TotalCellChars+1)})
+	if err == nil || !strings.Contains(err.Error(), "note") {
+		t.Errorf("got %v, want a too long error", err)
+	}
+}
+
+func TestXLSXWriter_Rollover(t *testing.T) {
+	defer func(n int) { xlsxMaxRows = n }(xlsxMaxRows)
+	xlsxMaxRows = 3
+
+	dir := t.TempDir()
+	w := newXLSXWriter(WriterOptions{Table: "a_very_long_table_name_over_31_chars", Output: filepath.Join(dir, "out.xlsx")})
+	cols := []Column{{Name: "n", DatabaseType: "INT"}}
+	var data [][]interface{}
+	for i := int64(1); i <= 5; i++ {
+		data = append(data, []interface{}{i})
+	}
+	res, err := runExport(context.Background(), &staticRows{cols: []string{"n"}, data: data}, cols, w, exportRun{batchSize: 10})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	f, err := excelize.OpenFile(res.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sheets := f.GetSheetList()
+	want := []string{"a_very_long_table_name_over_31_", "a_very_long_table_name_over (2)", "a_very_long_table_name_over (3)"}
+	if strings.Join(sheets, "|") != strings.Join(want, "|") {
+		t.Fatalf("sheets %q, want %q", sheets, want)
+	}
+	var got []string
+	for _, sheet := range sheets {
+		rows, _ := f.GetRows(sheet)
+		if rows[0][0] != "n" {
+			t.Errorf("sheet %s has no header", sheet)
+		}
+		for _, row := range rows[1:] {
+			got = append(got, row[0])
+		}
+	}
+	if strings.Join(got, ",") != "1,2,3,4,5" {
+		t.Errorf("rows %v, want 1 to 5", got)
+	}
+}
@@ -0,0 +1,182 @@
+package dbexport
+
+import (
+	"fmt"
+	"getmssql/i18n"
+	"io"
+	"strings"
+	"time"
+)
+
+// ProgressReporter receives progress updates during an export.
+//
+// Start is called once the total row count is known (0 when unknown), Update
+// periodically with the number of rows exported so far, and Finish once at the
+// end with the final count and the error that ended the export, if any.
+type ProgressReporter interface {
+	Start(table string, totalRows int64)
+	Update(rowsDone int64)
+	Finish(rowsDone int64, err error)
+}
+
+// progressInterval is how many rows pass between Update calls.
+const progressInterval = 1000
+
+// NoProgress is a ProgressReporter that reports nothing. It is the default
+// for library callers that do not set ExportOptions.Progress.
+var NoProgress ProgressReporter = noProgress{}
+
+type noProgress struct{}
+
+func (noProgress) Start(string, int64) {}
+func (noProgress) Update(int64)        {}
+func (noProgress) Finish(int64, error) {}
+
+// progressClock tracks throughput and estimates the remaining time.
+type progressClock struct {
+	now   func() time.Time
+	start time.Time
+	total int64
+}
+
+func (c *progressClock) begin(total int64) {
+	if c.now == nil {
+		c.now = time.Now
+	}
+	c.start = c.now()
+	c.total = total
+}
+
+// stats returns the completion percentage (or -1 when the total is unknown),
+// the rows per second so far and the estimated time remaining (or -1).
+func (c *progressClock) stats(done int64) (percent, rate float64, eta time.Duration) {
+	elapsed := c.now().Sub(c.start).Seconds()
+	if elapsed > 0 {
+		rate = float64(done) / elapsed
+	}
+	percent, eta = -1, -1
+	if c.total > 0 {
+		percent = float64(done) * 100 / float64(c.total)
+		if percent > 100 {
+			percent = 100
+		}
+		if rate > 0 && done <= c.total {
+			eta = time.Duration(float64(c.total-done) / rate * float64(time.Second)).Round(time.Second)
+		}
+	}
+	return percent, rate, eta
+}
+
+// terminalProgress redraws a single progress bar line using carriage returns.
+type terminalProgress struct {
+	out      io.Writer
+	clock    progressClock
+	minDelay time.Duration
+	lastDraw time.Time
+	drawn    bool
+}
+
+// NewTerminalProgress returns a ProgressReporter that draws a progress bar
+// with percentage, rows/sec and ETA on an interactive terminal.
+func NewTerminalProgress(out io.Writer) ProgressReporter {
+	return &terminalProgress{out: out, minDelay: 100 * time.Millisecond}
+}
+
+func (p *terminalProgress) Start(table string, totalRows int64) {
+	p.clock.begin(totalRows)
+	if totalRows > 0 {
+		fmt.Fprintln(p.out, i18n.T("progress.start_total", table, totalRows))
+	} else {
+		fmt.Fprintln(p.out, i18n.T("progress.start", table))
+	}
+}
+
+func (p *terminalProgress) Update(rowsDone int64) {
+	now := p.clock.now()
+	if p.drawn && now.Sub(p.lastDraw) < p.minDelay {
+		return
+	}
+	p.lastDraw = now
+	p.draw(rowsDone)
+}
+
+func (p *terminalProgress) draw(rowsDone int64) {
+	const width = 30
+	percent, rate, eta := p.clock.stats(rowsDone)
+	if percent < 0 {
+		fmt.Fprintf(p.out, "\r%s   ", i18n.T("progress.bar_rows", rowsDone, rate))
+	} else {
+		filled := int(percent / 100 * width)
+		bar := strings.Repeat("#", filled) + strings.Repeat("-", width-filled)
+		fmt.Fprintf(p.out, "\r[%s] %s   ", bar, i18n.T("progress.bar", percent, rowsDone, p.clock.total, rate, formatETA(eta)))
+	}
+	p.drawn = true
+}
+
+func (p *terminalProgress) Finish(rowsDone int64, err error) {
+	if err != nil {
+		if p.drawn {
+			fmt.Fprintln(p.out)
+		}
+		return
+	}
+	if rowsDone > 0 {
+		p.draw(rowsDone)
+		fmt.Fprintln(p.out)
+	}
+	fmt.Fprintln(p.out, i18n.T("progress.done", rowsDone))
+}
+
+// lineProgress writes one plain line per update, suitable for logs and CI.
+type lineProgress struct {
+	out      io.Writer
+	clock    progressClock
+	interval time.Duration
+	lastLine time.Time
+}
+
+// NewLineProgress returns a ProgressReporter that writes a plain progress line
+// at most once per interval, without carriage returns or a bar.
+func NewLineProgress(out io.Writer, interval time.Duration) ProgressReporter {
+	return &lineProgress{out: out, interval: interval}
+}
+
+func (p *lineProgress) Start(table string, totalRows int64) {
+	p.clock.begin(totalRows)
+	p.lastLine = p.clock.start
+	if totalRows > 0 {
+		fmt.Fprintln(p.out, i18n.T("progress.line_start_total", table, totalRows))
+	} else {
+		fmt.Fprintln(p.out, i18n.T("progress.line_start", table))
+	}
+}
+
+func (p *lineProgress) Update(rowsDone int64) {
+	now := p.clock.now()
+	if now.Sub(p.lastLine) < p.interval {
+		return
+	}
+	p.lastLine = now
+	percent, rate, eta := p.clock.stats(rowsDone)
+	if percent < 0 {
+		fmt.Fprintln(p.out, i18n.T("progress.line_rows", rowsDone, rate))
+		return
+	}
+	fmt.Fprintln(p.out, i18n.T("progress.line", rowsDone, p.clock.total, percent, rate, formatETA(eta)))
+}
+
+func (p *lineProgress) Finish(rowsDone int64, err error) {
+	if err != nil {
+		fmt.Fprintln(p.out, i18n.T("progress.stopped", rowsDone))
+		return
+	}
+	fmt.Fprintln(p.out, i18n.T("progress.done", rowsDone))
+}
+
+// formatETA renders an ETA, or "?" when it cannot be estimated yet.
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "?"
+	}
+	return eta.String()
+}
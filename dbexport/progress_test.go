@@ -0,0 +1,90 @@
+package dbexport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a now func that advances by step on every call
+func fakeClock(step time.Duration) func() time.Time {
+	t := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		t = t.Add(step)
+		return t
+	}
+}
+
+func TestProgressClock_Stats(t *testing.T) {
+	c := progressClock{now: fakeClock(10 * time.Second)}
+	c.begin(1000)
+	percent, rate, eta := c.stats(250)
+	if percent != 25 || rate != 25 || eta != 30*time.Second {
+		t.Errorf("unexpected stats: %v%% %v rows/s ETA %v", percent, rate, eta)
+	}
+
+	c = progressClock{now: fakeClock(time.Second)}
+	c.begin(0)
+	percent, _, eta = c.stats(10)
+	if percent != -1 || eta != -1 {
+		t.Errorf("expected unknown percent and ETA without a total, got %v %v", percent, eta)
+	}
+}
+
+func TestTerminalProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewTerminalProgress(&buf).(*terminalProgress)
+	p.clock.now = fakeClock(time.Second)
+	p.Start("orders", 100)
+	p.Update(50)
+	p.Finish(100, nil)
+	out := buf.String()
+	for _, want := range []string{
+		"Starting download of table 'orders'... (total rows: 100)",
+		"\r[###############---------------]  50.0%  50/100 rows",
+		"ETA ",
+		"100.0%  100/100 rows",
+		"Total rows downloaded: 100\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got: %q", want, out)
+		}
+	}
+
+	// An error ends the bar line without a total
+	buf.Reset()
+	p.Finish(10, fmt.Errorf("boom"))
+	if buf.String() != "\n" {
+		t.Errorf("unexpected output on error: %q", buf.String())
+	}
+}
+
+func TestLineProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewLineProgress(&buf, 5*time.Second).(*lineProgress)
+	p.clock.now = fakeClock(3 * time.Second)
+	p.Start("orders", 0)
+	p.Update(10) // 3s since start: throttled
+	p.Update(20) // 6s since start: printed
+	p.Finish(20, nil)
+	want := "Starting download of table 'orders'\nDownloaded 20 rows, 2 rows/s\nTotal rows downloaded: 20\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%q\nwant:\n%q", buf.String(), want)
+	}
+	if strings.Contains(buf.String(), "\r") {
+		t.Error("plain progress must not use carriage returns")
+	}
+}
+
+func TestNoProgress(t *testing.T) {
+	out := captureStdout(func() {
+		NoProgress.Start("t", 1)
+		NoProgress.Update(1)
+		NoProgress.Finish(1, nil)
+	})
+	if out != "" {
+		t.Errorf("expected no output, got: %q", out)
+	}
+}
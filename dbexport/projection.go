@@ -0,0 +1,227 @@
+package dbexport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"getmssql/i18n"
+	"strings"
+)
+
+// sourceColumn is a column of the exported table as declared on the server.
+type sourceColumn struct {
+	Name     string
+	DataType string // INFORMATION_SCHEMA data type, e.g. "geography"
+}
+
+// spatialEncoding selects how geography and geometry values are read.
+type spatialEncoding int
+
+const (
+	spatialWKT spatialEncoding = iota // STAsText(), for the text formats
+	spatialWKB                        // STAsBinary(), for BLOB columns
+)
+
+// wkbStorer is implemented by writers that store geography and geometry
+// values as WKB rather than WKT.
+type wkbStorer interface {
+	storesWKB() bool
+}
+
+// spatialEncodingFor returns the spatial encoding w wants.
+func spatialEncodingFor(w Writer) spatialEncoding {
+	if s, ok := w.(wkbStorer); ok && s.storesWKB() {
+		return spatialWKB
+	}
+	return spatialWKT
+}
+
+// sourceColumnsContext reads the declared columns of table, which may be
+// qualified with its schema. An unqualified name is resolved with OBJECT_ID,
+// as the SELECT resolves it, so that same-named tables of other schemas are
+// left out. It returns no columns, rather than an error, for objects
+// INFORMATION_SCHEMA does not list.
+func sourceColumnsContext(ctx context.Context, db *sql.DB, table string) ([]sourceColumn, error) {
+	schema, name := splitTableName(table)
+	query := `SELECT COLUMN_NAME, DATA_TYPE FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1`
+	args := []interface{}{name}
+	if schema != "" {
+		query += ` AND TABLE_SCHEMA = @p2`
+		args = append(args, schema)
+	} else {
+		query += ` AND TABLE_SCHEMA = OBJECT_SCHEMA_NAME(OBJECT_ID(@p2))`
+		args = append(args, quoteIdent(name))
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY ORDINAL_POSITION`, args...)
+	if err != nil {
+		return nil, i18n.Errorf("download.columns_error", ClassifyError(err))
+	}
+	defer rows.Close()
+	var cols []sourceColumn
+	for rows.Next() {
+		var col sourceColumn
+		if err := rows.Scan(&col.Name, &col.DataType); err != nil {
+			return nil, i18n.Errorf("download.columns_error", err)
+		}
+		cols = append(cols, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, i18n.Errorf("download.columns_error", ClassifyError(err))
+	}
+	return cols, nil
+}
+
+// variantTypeSuffix names the extra column holding the base type of a
+// sql_variant column: column "v" is followed by "v_basetype".
+const variantTypeSuffix = "_basetype"
+
+// projection returns the select list expression reading col, converting the
+// types the driver cannot read on the server. It returns "" for columns that
+// are selected as they are.
+//
+// A sql_variant column is read as text, with dates in ISO 8601, and is
+// followed by a column with its SQL_VARIANT_PROPERTY base type.
+func (col sourceColumn) projection(spatial spatialEncoding) string {
+	name := quoteIdent(col.Name)
+	switch typ := strings.ToLower(col.DataType); {
+	case isSpatialType(typ):
+		method := "STAsText"
+		if spatial == spatialWKB {
+			method = "STAsBinary"
+		}
+		return fmt.Sprintf("%s.%s() AS %s", name, method, name)
+	case typ == "xml":
+		return fmt.Sprintf("CAST(%s AS nvarchar(max)) AS %s", name, name)
+	case typ == "hierarchyid":
+		return fmt.Sprintf("%s.ToString() AS %s", name, name)
+	case typ == "sql_variant":
+		baseType := fmt.Sprintf("SQL_VARIANT_PROPERTY(%s, 'BaseType')", name)
+		return fmt.Sprintf("CASE WHEN %s IN ('date', 'time', 'datetime', 'datetime2', 'smalldatetime', 'datetimeoffset') "+
+			"THEN CONVERT(nvarchar(4000), %s, 126) ELSE CAST(%s AS nvarchar(4000)) END AS %s, CAST(%s AS nvarchar(128)) AS %s",
+			baseType, name, name, name, baseType, quoteIdent(col.Name+variantTypeSuffix))
+	}
+	return ""
+}
+
+// selectList returns the select list for fields (all columns when empty),
+// replacing the columns that need a server-side conversion by their
+// projection. Fields that are not plain column names are kept as given, and
+// "*" is kept when no column needs a conversion.
+func selectList(fields []string, source []sourceColumn, spatial spatialEncoding) []string {
+	byName := make(map[string]sourceColumn, len(source))
+	for _, col := range source {
+		byName[strings.ToLower(col.Name)] = col
+	}
+	if len(fields) == 0 {
+		projected := false
+		list := make([]string, len(source))
+		for i, col := range source {
+			if list[i] = col.projection(spatial); list[i] != "" {
+				projected = true
+			} else {
+				list[i] = quoteIdent(col.Name)
+			}
+		}
+		if !projected {
+			return nil
+		}
+		return list
+	}
+	list := make([]string, len(fields))
+	for i, field := range fields {
+		list[i] = field
+		if col, ok := byName[strings.ToLower(unquoteIdent(field))]; ok {
+			if p := col.projection(spatial); p != "" {
+				list[i] = p
+			}
+		}
+	}
+	return list
+}
+
+// applySourceTypes sets the type of the result columns read through a
+// projection back to the declared type, so that writers see e.g. GEOGRAPHY
+// instead of the NVARCHAR the conversion returns.
+func applySourceTypes(cols []Column, source []sourceColumn, spatial spatialEncoding) {
+	byName := make(map[string]sourceColumn, len(source))
+	for _, col := range source {
+		byName[strings.ToLower(col.Name)] = col
+	}
+	for i := range cols {
+		if src, ok := byName[strings.ToLower(cols[i].Name)]; ok && src.projection(spatial) != "" {
+			cols[i].DatabaseType = strings.ToUpper(src.DataType)
+		}
+	}
+}
+
+// splitTableName splits a table name such as "sales.orders" or
+// "[sales].[orders]" into its schema, "" when not given, and name. Names with
+// more parts are rejected by checkTableName.
+func splitTableName(table string) (schema, name string) {
+	parts := tableNameParts(table)
+	name = parts[len(parts)-1]
+	if len(parts) > 1 {
+		schema = parts[len(parts)-2]
+	}
+	return schema, name
+}
+
+// checkTableName reports an error for table names with more than a schema and
+// a name: the database of "db.sales.orders" would be dropped, and the table of
+// the connection's database read instead.
+func checkTableName(table string) error {
+	if len(tableNameParts(table)) > 2 {
+		return i18n.Errorf("download.table_name_parts", table)
+	}
+	return nil
+}
+
+// tableNameParts splits a table name at the dots outside brackets and removes
+// the brackets.
+func tableNameParts(table string) []string {
+	var parts []string
+	var part strings.Builder
+	quoted := false
+	for i := 0; i < len(table); i++ {
+		switch c := table[i]; {
+		case c == '[' && !quoted && part.Len() == 0:
+			quoted = true
+		case c == ']' && quoted:
+			if i+1 < len(table) && table[i+1] == ']' {
+				part.WriteByte(']')
+				i++
+			} else {
+				quoted = false
+			}
+		case c == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// quoteTable quotes a table name, qualified with its schema if given.
+func quoteTable(table string) string {
+	schema, name := splitTableName(table)
+	if schema == "" {
+		return quoteIdent(name)
+	}
+	return quoteIdent(schema) + "." + quoteIdent(name)
+}
+
+// quoteIdent quotes an MSSQL identifier in brackets.
+func quoteIdent(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// unquoteIdent strips the brackets of an identifier quoted by quoteIdent.
+func unquoteIdent(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) >= 2 && name[0] == '[' && name[len(name)-1] == ']' {
+		return strings.ReplaceAll(name[1:len(name)-1], "]]", "]")
+	}
+	return name
+}
@@ -0,0 +1,194 @@
+package dbexport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSelectList(t *testing.T) {
+	source := []sourceColumn{{"id", "int"}, {"shape", "geography"}, {"odd]name", "geometry"}}
+	cases := []struct {
+		name    string
+		fields  []string
+		source  []sourceColumn
+		spatial spatialEncoding
+		want    []string
+	}{
+		{"all, nothing to convert", nil, source[:1], spatialWKT, nil},
+		{"all as WKT", nil, source, spatialWKT, []string{"[id]", "[shape].STAsText() AS [shape]", "[odd]]name].STAsText() AS [odd]]name]"}},
+		{"all as WKB", nil, source[:2], spatialWKB, []string{"[id]", "[shape].STAsBinary() AS [shape]"}},
+		{"fields", []string{"ID", "[Shape]", "id + 1 AS next"}, source, spatialWKT, []string{"ID", "[shape].STAsText() AS [shape]", "id + 1 AS next"}},
+		{"unknown table", []string{"a"}, nil, spatialWKT, []string{"a"}},
+	}
+	for _, c := range cases {
+		if got := selectList(c.fields, c.source, c.spatial); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestApplySourceTypes(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "INT"}, {Name: "Shape", DatabaseType: "NVARCHAR"}}
+	applySourceTypes(cols, []sourceColumn{{"id", "int"}, {"shape", "geography"}}, spatialWKT)
+	if cols[0].DatabaseType != "INT" || cols[1].DatabaseType != "GEOGRAPHY" {
+		t.Errorf("unexpected types: %+v", cols)
+	}
+}
+
+func TestDownloadTable_SpatialProjection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM \[table\]`).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	expectSourceColumns(mock, "id", "int", "shape", "geography")
+	mock.ExpectQuery(`SELECT \[id\], \[shape\]\.STAsText\(\) AS \[shape\] FROM \[table\]`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shape"}).AddRow(1, "POINT (1 2)"))
+	w := &stubWriter{}
+	if _, err := DownloadTable(db, "table", ExportOptions{Writer: w}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(w.cols) != 2 || w.cols[1].DatabaseType != "GEOGRAPHY" {
+		t.Errorf("expected the shape column typed GEOGRAPHY, got %+v", w.cols)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSourceColumn_Projection(t *testing.T) {
+	cases := map[sourceColumn]string{
+		{"doc", "xml"}:          "CAST([doc] AS nvarchar(max)) AS [doc]",
+		{"node", "hierarchyid"}: "[node].ToString() AS [node]",
+		{"v", "sql_variant"}: "CASE WHEN SQL_VARIANT_PROPERTY([v], 'BaseType') IN ('date', 'time', 'datetime', 'datetime2', 'smalldatetime', 'datetimeoffset') " +
+			"THEN CONVERT(nvarchar(4000), [v], 126) ELSE CAST([v] AS nvarchar(4000)) END AS [v], " +
+			"CAST(SQL_VARIANT_PROPERTY([v], 'BaseType') AS nvarchar(128)) AS [v_basetype]",
+		{"name", "nvarchar"}: "",
+	}
+	for col, want := range cases {
+		if got := col.projection(spatialWKT); got != want {
+			t.Errorf("%s: got %q, want %q", col.DataType, got, want)
+		}
+	}
+}
+
+func TestSplitTableName(t *testing.T) {
+	cases := []struct{ in, schema, name, quoted string }{
+		{"orders", "", "orders", "[orders]"},
+		{"sales.orders", "sales", "orders", "[sales].[orders]"},
+		{"[sales].[order details]", "sales", "order details", "[sales].[order details]"},
+		{"[odd.name]", "", "odd.name", "[odd.name]"},
+		{"[a]]b]", "", "a]b", "[a]]b]"},
+	}
+	for _, c := range cases {
+		schema, name := splitTableName(c.in)
+		if schema != c.schema || name != c.name || quoteTable(c.in) != c.quoted {
+			t.Errorf("%s: got %q %q %q, want %q %q %q", c.in, schema, name, quoteTable(c.in), c.schema, c.name, c.quoted)
+		}
+	}
+}
+
+func TestCheckTableName(t *testing.T) {
+	for in, ok := range map[string]bool{
+		"orders":                 true,
+		"sales.orders":           true,
+		"[odd.name].[a.b]":       true,
+		"db.sales.orders":        false,
+		"[db].[sales].[orders]":  false,
+		"server.db.sales.orders": false,
+	} {
+		if err := checkTableName(in); (err == nil) != ok {
+			t.Errorf("%s: got %v", in, err)
+		}
+	}
+
+	// Three-part names fail before any query runs
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	if _, err := DownloadTable(db, "db.sales.orders", ExportOptions{Writer: &stubWriter{}}); err == nil || !strings.Contains(err.Error(), "db.sales.orders") {
+		t.Errorf("got %v, want a table name error", err)
+	}
+	if _, err := BuildSelectQueryContext(context.Background(), db, "db.sales.orders", ""); err == nil {
+		t.Error("expected a table name error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuildSelectQueryContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	source := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE"}).
+			AddRow("id", "int").AddRow("doc", "xml").AddRow("node", "hierarchyid")
+	}
+
+	// SELECT * is expanded when a column needs a conversion
+	mock.ExpectQuery(`INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND TABLE_SCHEMA = @p2`).
+		WithArgs("docs", "sales").WillReturnRows(source())
+	query, err := BuildSelectQueryContext(context.Background(), db, "sales.docs", "")
+	want := "SELECT [id], CAST([doc] AS nvarchar(max)) AS [doc], [node].ToString() AS [node] FROM [sales].[docs]"
+	if err != nil || query != want {
+		t.Errorf("got %q (err: %v), want %q", query, err, want)
+	}
+
+	// Fields-file selections convert the listed columns only
+	fname := filepath.Join(t.TempDir(), "fields.txt")
+	if err := os.WriteFile(fname, []byte("id\n[node]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND TABLE_SCHEMA = OBJECT_SCHEMA_NAME\(OBJECT_ID\(@p2\)\) ORDER BY`).
+		WithArgs("docs", "[docs]").WillReturnRows(source())
+	query, err = BuildSelectQueryContext(context.Background(), db, "docs", fname)
+	want = "SELECT id, [node].ToString() AS [node] FROM [docs]"
+	if err != nil || query != want {
+		t.Errorf("got %q (err: %v), want %q", query, err, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSourceColumnsContext_SchemaShadowing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	// dbo.t has (id, doc) and audit.t has (id, changed_by, doc); an unqualified
+	// name reads the columns of the table OBJECT_ID resolves only
+	mock.ExpectQuery(`INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND TABLE_SCHEMA = OBJECT_SCHEMA_NAME\(OBJECT_ID\(@p2\)\)`).
+		WithArgs("t", "[t]").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE"}).AddRow("id", "int").AddRow("doc", "xml"))
+	query, err := BuildSelectQueryContext(context.Background(), db, "t", "")
+	want := "SELECT [id], CAST([doc] AS nvarchar(max)) AS [doc] FROM [t]"
+	if err != nil || query != want {
+		t.Errorf("got %q (err: %v), want %q", query, err, want)
+	}
+
+	mock.ExpectQuery(`INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @p1 AND TABLE_SCHEMA = @p2 ORDER BY`).
+		WithArgs("t", "audit").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE"}).AddRow("id", "int").AddRow("changed_by", "nvarchar").AddRow("doc", "xml"))
+	query, err = BuildSelectQueryContext(context.Background(), db, "audit.t", "")
+	want = "SELECT [id], [changed_by], CAST([doc] AS nvarchar(max)) AS [doc] FROM [audit].[t]"
+	if err != nil || query != want {
+		t.Errorf("got %q (err: %v), want %q", query, err, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
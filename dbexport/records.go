@@ -0,0 +1,166 @@
+package dbexport
+
+import (
+	"context"
+	"database/sql"
+	"getmssql/i18n"
+	"sync/atomic"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+)
+
+// ReadRecords returns the rows of table as Arrow records, see ReadRecordsContext.
+func ReadRecords(db *sql.DB, table string, opts ExportOptions) (array.RecordReader, error) {
+	return ReadRecordsContext(context.Background(), db, table, opts)
+}
+
+// ReadRecordsContext runs the query of an export of table and returns its
+// rows as Arrow records of up to opts.BatchSize rows, typed as the arrow and
+// parquet formats write them. It reads the same options as
+// DownloadTableContext except Format, Writer and Progress; of WriterOptions
+// only Types and the text options of string columns apply.
+//
+// Rows that cannot be converted are handled by opts.OnRowError, and an error
+// that ends the result set is returned by the reader's Err. The caller must
+// Release the reader, which closes the query; cancelling ctx stops it.
+func ReadRecordsContext(ctx context.Context, db *sql.DB, table string, opts ExportOptions) (array.RecordReader, error) {
+	q, err := newTableQuery(table, opts)
+	if err != nil {
+		return nil, err
+	}
+	spatial := spatialWKB
+	if opts.WriterOptions.Types == TypesText {
+		spatial = spatialWKT
+	}
+	rows, cols, err := q.run(ctx, db, spatial)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &recordReader{
+		refs:      1,
+		ctx:       ctx,
+		rows:      rows,
+		cols:      cols,
+		enc:       newArrowEncoder(cols, opts.WriterOptions),
+		batchSize: batchSize,
+		rowErrs:   newRowErrorHandler(opts.OnRowError, opts.RejectFile),
+	}, nil
+}
+
+// recordReader is the array.RecordReader of ReadRecordsContext.
+type recordReader struct {
+	refs      int64
+	ctx       context.Context
+	rows      *sql.Rows
+	cols      []Column
+	enc       *arrowEncoder
+	batchSize int
+	rowErrs   *rowErrorHandler
+	ordinal   int64
+	rec       arrow.Record
+	err       error
+	done      bool
+}
+
+// Retain increases the reference count of the reader.
+func (r *recordReader) Retain() {
+	atomic.AddInt64(&r.refs, 1)
+}
+
+// Release decreases the reference count of the reader and, when it drops to
+// zero, releases the current record and closes the query.
+func (r *recordReader) Release() {
+	if atomic.AddInt64(&r.refs, -1) != 0 {
+		return
+	}
+	if r.rec != nil {
+		r.rec.Release()
+		r.rec = nil
+	}
+	r.finish(nil)
+	r.enc.release()
+}
+
+// Schema returns the Arrow schema of the records.
+func (r *recordReader) Schema() *arrow.Schema {
+	return r.enc.schema
+}
+
+// Next reads the next record, reporting false at the end of the rows or on
+// an error.
+func (r *recordReader) Next() bool {
+	if r.rec != nil {
+		r.rec.Release()
+		r.rec = nil
+	}
+	if r.done {
+		return false
+	}
+	batch := make([][]interface{}, 0, r.batchSize)
+	for len(batch) < r.batchSize {
+		if !r.rows.Next() {
+			r.finish(r.rowsErr())
+			break
+		}
+		if err := r.ctx.Err(); err != nil {
+			r.finish(i18n.Errorf("download.cancelled", err))
+			return false
+		}
+		r.ordinal++
+		vals, err := scanRow(r.rows, r.cols, nil, r.enc)
+		if err != nil {
+			if err := r.rowErrs.handle(&RowError{Row: r.ordinal, Err: err}); err != nil {
+				r.finish(err)
+				return false
+			}
+			continue
+		}
+		batch = append(batch, vals)
+	}
+	if len(batch) == 0 || r.err != nil {
+		return false
+	}
+	r.rec = r.enc.record(batch)
+	return true
+}
+
+// rowsErr returns the error that ended the rows, if any.
+func (r *recordReader) rowsErr() error {
+	// A cancelled query ends the result set early; report the cancellation, not the driver error
+	if err := r.ctx.Err(); err != nil {
+		return i18n.Errorf("download.cancelled", err)
+	}
+	if err := r.rows.Err(); err != nil {
+		return i18n.Errorf("rows.error", ClassifyError(err))
+	}
+	return nil
+}
+
+// finish closes the query and the reject file once, keeping the first error.
+func (r *recordReader) finish(err error) {
+	if r.done {
+		return
+	}
+	r.done = true
+	r.rows.Close()
+	if closeErr := r.rowErrs.close(); err == nil {
+		err = closeErr
+	}
+	r.err = err
+}
+
+// Record returns the current record. It is valid until the next call to
+// Next or Release; Retain it to keep it longer.
+func (r *recordReader) Record() arrow.Record {
+	return r.rec
+}
+
+// Err returns the error that stopped the reader, if any.
+func (r *recordReader) Err() error {
+	return r.err
+}
@@ -0,0 +1,125 @@
+package dbexport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/arrow-go/v18/arrow/array"
+)
+
+func TestReadRecords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	expectSourceColumns(mock, "id", "int", "qty", "smallint", "name", "nvarchar")
+	rows := mock.NewRowsWithColumnDefinition(
+		mock.NewColumn("id").OfType("INT", int64(0)).Nullable(false),
+		mock.NewColumn("qty").OfType("SMALLINT", int64(0)).Nullable(true),
+		mock.NewColumn("name").OfType("NVARCHAR", "").Nullable(true),
+	).AddRow(int64(1), int64(10), "a").
+		AddRow(int64(2), int64(70000), "too big").
+		AddRow(int64(3), nil, "c").
+		AddRow(int64(4), int64(40), nil)
+	mock.ExpectQuery(`SELECT \* FROM \[items\] WHERE id > 0`).WillReturnRows(rows)
+
+	rr, err := ReadRecords(db, "items", ExportOptions{Where: "id > 0", BatchSize: 2, OnRowError: RowErrorSkip})
+	if err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	defer rr.Release()
+	want := []string{"id: type=int32", "qty: type=int16, nullable", "name: type=utf8, nullable"}
+	for i, f := range rr.Schema().Fields() {
+		if f.String() != want[i] {
+			t.Errorf("field %d: %s, want %s", i, f, want[i])
+		}
+	}
+
+	var ids []int32
+	var sizes []int64
+	for rr.Next() {
+		rec := rr.Record()
+		sizes = append(sizes, rec.NumRows())
+		for i := 0; i < int(rec.NumRows()); i++ {
+			ids = append(ids, rec.Column(0).(*array.Int32).Value(i))
+		}
+		if rec.NumRows() == 1 && !rec.Column(2).IsNull(0) {
+			t.Error("NULL name was not kept")
+		}
+	}
+	if err := rr.Err(); err != nil {
+		t.Fatalf("reader error: %v", err)
+	}
+	// Row 2 does not fit in a smallint and is skipped
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 3 || ids[2] != 4 || len(sizes) != 2 || sizes[0] != 2 {
+		t.Errorf("got ids %v in records of %v rows", ids, sizes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadRecords_Errors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// A failing row stops the reader with the default policy
+	expectSourceColumns(mock)
+	mock.ExpectQuery(`SELECT \* FROM \[items\]`).WillReturnRows(mock.NewRowsWithColumnDefinition(
+		mock.NewColumn("n").OfType("TINYINT", int64(0)).Nullable(true),
+	).AddRow(int64(1)).AddRow(int64(-1)))
+	rr, err := ReadRecords(db, "items", ExportOptions{})
+	if err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if rr.Next() {
+		t.Error("expected no record")
+	}
+	var rowErr *RowError
+	if !errors.As(rr.Err(), &rowErr) || rowErr.Row != 2 {
+		t.Errorf("got %v, want a RowError for row 2", rr.Err())
+	}
+	rr.Release()
+
+	// A failing query is returned by ReadRecords
+	expectSourceColumns(mock)
+	mock.ExpectQuery(`SELECT \* FROM \[items\]`).WillReturnError(errors.New("boom"))
+	if _, err := ReadRecords(db, "items", ExportOptions{}); err == nil || !strings.Contains(err.Error(), "error querying table rows") {
+		t.Errorf("got %v, want a query error", err)
+	}
+
+	// An unknown types file column fails before any record is read
+	expectSourceColumns(mock)
+	mock.ExpectQuery(`SELECT \* FROM \[items\]`).WillReturnRows(sqlmock.NewRows([]string{"n"}))
+	_, err = ReadRecords(db, "items", ExportOptions{ColumnTypes: map[string]ColumnType{"missing": {Type: TypeInt}}})
+	if err == nil || !strings.Contains(err.Error(), "not exported") {
+		t.Errorf("got %v, want an unknown column error", err)
+	}
+}
+
+func TestReadRecords_Retain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	expectSourceColumns(mock)
+	mock.ExpectQuery(`SELECT \* FROM \[items\]`).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow("x"))
+	rr, err := ReadRecords(db, "items", ExportOptions{})
+	if err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	rr.Retain()
+	rr.Release()
+	if !rr.Next() || rr.Record().Column(0).(*array.String).Value(0) != "x" {
+		t.Fatal("expected one record after Retain and Release")
+	}
+	rr.Release()
+}
@@ -0,0 +1,108 @@
+package dbexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"getmssql/i18n"
+	"io"
+	"os"
+)
+
+// RowError reports a row that could not be read or converted.
+// Row is the 1-based position of the row in the result set.
+type RowError struct {
+	Row int64
+	Err error
+}
+
+func (e *RowError) Error() string { return i18n.T("rows.row_error", e.Row, e.Err) }
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// rejectRecord is one line of a reject file.
+type rejectRecord struct {
+	Row   int64  `json:"row"`
+	Error string `json:"error"`
+}
+
+// rowErrorHandler applies a RowErrorPolicy to failed rows and records them,
+// one JSON object per line, in a reject file that is created on first use.
+type rowErrorHandler struct {
+	policy RowErrorPolicy
+	path   string
+	warn   io.Writer // where RowErrorLog reports skipped rows
+	file   *os.File
+	count  int64
+}
+
+// newRowErrorHandler returns a handler for opts, or nil when rows errors
+// simply fail the export and there is no reject file.
+func newRowErrorHandler(policy RowErrorPolicy, rejectFile string) *rowErrorHandler {
+	if policy == RowErrorFail && rejectFile == "" {
+		return nil
+	}
+	return &rowErrorHandler{policy: policy, path: rejectFile, warn: os.Stderr}
+}
+
+// handle records the failed row and returns the error that stops the export,
+// or nil when the policy is to skip the row and carry on.
+func (h *rowErrorHandler) handle(rowErr *RowError) error {
+	if h == nil {
+		return rowErr
+	}
+	h.count++
+	if err := h.record(rowErr); err != nil {
+		return err
+	}
+	switch h.policy {
+	case RowErrorSkip:
+		return nil
+	case RowErrorLog:
+		fmt.Fprintln(h.warn, i18n.T("rows.skipped", rowErr))
+		return nil
+	}
+	return rowErr
+}
+
+func (h *rowErrorHandler) record(rowErr *RowError) error {
+	if h.path == "" {
+		return nil
+	}
+	if h.file == nil {
+		file, err := os.Create(h.path)
+		if err != nil {
+			return i18n.Errorf("rejects.create_error", err)
+		}
+		h.file = file
+	}
+	line, err := json.Marshal(rejectRecord{Row: rowErr.Row, Error: rowErr.Err.Error()})
+	if err != nil {
+		return i18n.Errorf("rejects.write_error", err)
+	}
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return i18n.Errorf("rejects.write_error", err)
+	}
+	return nil
+}
+
+// rejected returns the number of failed rows and the reject file they were
+// written to, if any.
+func (h *rowErrorHandler) rejected() (int64, string) {
+	if h == nil {
+		return 0, ""
+	}
+	if h.file == nil {
+		return h.count, ""
+	}
+	return h.count, h.path
+}
+
+func (h *rowErrorHandler) close() error {
+	if h == nil || h.file == nil {
+		return nil
+	}
+	if err := h.file.Close(); err != nil {
+		return i18n.Errorf("rejects.write_error", err)
+	}
+	return nil
+}
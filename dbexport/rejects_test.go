@@ -0,0 +1,152 @@
+package dbexport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// flakyRows returns one single-column row per value and fails to scan the
+// rows whose 1-based position is in failAt.
+type flakyRows struct {
+	values []interface{}
+	failAt map[int]bool
+	pos    int
+}
+
+func (r *flakyRows) Next() bool {
+	r.pos++
+	return r.pos <= len(r.values)
+}
+
+func (r *flakyRows) Scan(dest ...interface{}) error {
+	if r.failAt[r.pos] {
+		return fmt.Errorf("converting row %d failed", r.pos)
+	}
+	*dest[0].(*interface{}) = r.values[r.pos-1]
+	return nil
+}
+
+func (r *flakyRows) Columns() ([]string, error) { return []string{"a"}, nil }
+func (r *flakyRows) Close() error               { return nil }
+func (r *flakyRows) Err() error                 { return nil }
+
+func TestScanRow_ReturnsErrors(t *testing.T) {
+	_, err := ScanRowValues(&errScanRows{}, []string{"a"})
+	if err == nil || !strings.Contains(err.Error(), "error scanning row: scan error") {
+		t.Errorf("expected scan error, got: %v", err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT a, b FROM test").WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow(1, 2))
+	rows, err := db.Query("SELECT a, b FROM test")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	rows.Next()
+	// One destination for two columns makes Scan fail
+	if _, err := ScanRowMap(rows, []string{"a"}); err == nil {
+		t.Error("expected error from ScanRowMap")
+	}
+}
+
+func TestParseRowErrorPolicy(t *testing.T) {
+	for in, want := range map[string]RowErrorPolicy{"": RowErrorFail, "fail": RowErrorFail, "skip": RowErrorSkip, "log": RowErrorLog} {
+		got, err := ParseRowErrorPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRowErrorPolicy(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseRowErrorPolicy("ignore"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestRunExport_RowErrorFail(t *testing.T) {
+	rows := &flakyRows{values: []interface{}{"x", "y", "z"}, failAt: map[int]bool{2: true}}
+	w := &stubWriter{}
+	_, err := runExport(context.Background(), rows, []Column{{Name: "a"}}, w, exportRun{})
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 2 {
+		t.Fatalf("expected RowError for row 2, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "row 2: error scanning row: converting row 2 failed") {
+		t.Errorf("unexpected message: %v", err)
+	}
+	if !w.closed || len(w.rows) != 0 {
+		t.Errorf("expected writer closed without the partial batch, got %v", w.rows)
+	}
+}
+
+func TestRunExport_RowErrorFailWithRejectFile(t *testing.T) {
+	rejectFile := filepath.Join(t.TempDir(), "rejects.jsonl")
+	rows := &flakyRows{values: []interface{}{"x", "y"}, failAt: map[int]bool{2: true}}
+	_, err := runExport(context.Background(), rows, []Column{{Name: "a"}}, &stubWriter{}, exportRun{rowErrs: newRowErrorHandler(RowErrorFail, rejectFile)})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	data, err := os.ReadFile(rejectFile)
+	if err != nil || !strings.HasPrefix(string(data), `{"row":2,"error":`) {
+		t.Errorf("expected failed row in reject file, got %q (err: %v)", data, err)
+	}
+}
+
+func TestRunExport_RowErrorSkipAndLog(t *testing.T) {
+	for _, policy := range []RowErrorPolicy{RowErrorSkip, RowErrorLog} {
+		t.Run(string(policy), func(t *testing.T) {
+			rejectFile := filepath.Join(t.TempDir(), "rejects.jsonl")
+			rows := &flakyRows{values: []interface{}{"a", "b", "c", "d"}, failAt: map[int]bool{1: true, 3: true}}
+			w := &stubWriter{}
+			h := newRowErrorHandler(policy, rejectFile)
+			var warn bytes.Buffer
+			h.warn = &warn
+			res, err := runExport(context.Background(), rows, []Column{{Name: "a"}}, w, exportRun{batchSize: 1, rowErrs: h})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.RowsWritten != 2 || res.RowsRejected != 2 || res.RejectFile != rejectFile {
+				t.Errorf("unexpected result: %+v", res)
+			}
+			if len(w.rows) != 2 || w.rows[0][0] != "b" || w.rows[1][0] != "d" {
+				t.Errorf("expected rows b and d, got %v", w.rows)
+			}
+			data, err := os.ReadFile(rejectFile)
+			if err != nil {
+				t.Fatalf("reading reject file: %v", err)
+			}
+			want := `{"row":1,"error":"error scanning row: converting row 1 failed"}` + "\n" +
+				`{"row":3,"error":"error scanning row: converting row 3 failed"}` + "\n"
+			if string(data) != want {
+				t.Errorf("unexpected reject file:\n%s", data)
+			}
+			logged := strings.Count(warn.String(), "warning: skipping row")
+			if policy == RowErrorLog && logged != 2 || policy == RowErrorSkip && logged != 0 {
+				t.Errorf("unexpected warnings for %s: %q", policy, warn.String())
+			}
+		})
+	}
+}
+
+func TestRunExport_NoRejectFileWithoutFailures(t *testing.T) {
+	rejectFile := filepath.Join(t.TempDir(), "rejects.jsonl")
+	rows := &flakyRows{values: []interface{}{"a"}}
+	res, err := runExport(context.Background(), rows, []Column{{Name: "a"}}, &stubWriter{}, exportRun{rowErrs: newRowErrorHandler(RowErrorSkip, rejectFile)})
+	if err != nil || res.RowsRejected != 0 || res.RejectFile != "" {
+		t.Fatalf("unexpected result %+v (err: %v)", res, err)
+	}
+	if _, err := os.Stat(rejectFile); !os.IsNotExist(err) {
+		t.Error("expected no reject file when no row failed")
+	}
+}
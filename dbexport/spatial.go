@@ -0,0 +1,239 @@
+package dbexport
+
+import (
+	"getmssql/i18n"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// isSpatialType reports whether an MSSQL type is geography or geometry. The
+// driver cannot read these CLR types, so they are converted on the server
+// (see sourceColumn.projection) and the columns keep the source type name.
+func isSpatialType(databaseType string) bool {
+	switch strings.ToUpper(databaseType) {
+	case "GEOGRAPHY", "GEOMETRY":
+		return true
+	}
+	return false
+}
+
+// geoJSONGeometry is a GeoJSON geometry object (RFC 7946). Coordinates holds
+// nested []float64 positions; GeometryCollection uses Geometries instead.
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates,omitempty"`
+	Geometries  interface{} `json:"geometries,omitempty"`
+}
+
+// geoJSONTypes maps the WKT geometry types GeoJSON can represent to their
+// GeoJSON names. The SQL Server curve types (CIRCULARSTRING, COMPOUNDCURVE,
+// CURVEPOLYGON) and FULLGLOBE have no GeoJSON equivalent.
+var geoJSONTypes = map[string]string{
+	"POINT":              "Point",
+	"LINESTRING":         "LineString",
+	"POLYGON":            "Polygon",
+	"MULTIPOINT":         "MultiPoint",
+	"MULTILINESTRING":    "MultiLineString",
+	"MULTIPOLYGON":       "MultiPolygon",
+	"GEOMETRYCOLLECTION": "GeometryCollection",
+}
+
+// wktToGeoJSON parses WKT as written by STAsText() into a GeoJSON geometry.
+// Z values are kept as the third coordinate; M values are dropped.
+func wktToGeoJSON(wkt string) (*geoJSONGeometry, error) {
+	p := &wktParser{input: wkt}
+	p.next()
+	g, err := p.geometry()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.errorf()
+	}
+	return g, nil
+}
+
+// wktParser is a recursive descent parser over the WKT tokens: words,
+// numbers and the punctuation "(", ")" and ",".
+type wktParser struct {
+	input string
+	pos   int
+	tok   string // current token, "" at the end of the input
+}
+
+func (p *wktParser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos < len(p.input) && strings.ContainsRune("(),", rune(p.input[p.pos])) {
+		p.pos++
+	} else {
+		for p.pos < len(p.input) && !unicode.IsSpace(rune(p.input[p.pos])) && !strings.ContainsRune("(),", rune(p.input[p.pos])) {
+			p.pos++
+		}
+	}
+	p.tok = p.input[start:p.pos]
+}
+
+func (p *wktParser) errorf() error {
+	return i18n.Errorf("spatial.bad_wkt", p.pos, p.input)
+}
+
+func (p *wktParser) expect(tok string) error {
+	if p.tok != tok {
+		return p.errorf()
+	}
+	p.next()
+	return nil
+}
+
+// empty consumes the EMPTY keyword if it is the current token.
+func (p *wktParser) empty() bool {
+	if strings.EqualFold(p.tok, "EMPTY") {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) geometry() (*geoJSONGeometry, error) {
+	wktType := strings.ToUpper(p.tok)
+	typ, ok := geoJSONTypes[wktType]
+	if !ok {
+		return nil, i18n.Errorf("spatial.unsupported_type", p.tok)
+	}
+	p.next()
+	// Dimension markers of the OGC form, e.g. POINT Z (1 2 3)
+	switch strings.ToUpper(p.tok) {
+	case "Z", "M", "ZM":
+		p.next()
+	}
+	g := &geoJSONGeometry{Type: typ}
+	var err error
+	switch wktType {
+	case "POINT":
+		if p.empty() {
+			g.Coordinates = []float64{}
+			return g, nil
+		}
+		if err = p.expect("("); err == nil {
+			if g.Coordinates, err = p.position(); err == nil {
+				err = p.expect(")")
+			}
+		}
+	case "LINESTRING", "MULTIPOINT":
+		g.Coordinates, err = p.positions()
+	case "POLYGON", "MULTILINESTRING":
+		g.Coordinates, err = p.list(p.positions)
+	case "MULTIPOLYGON":
+		g.Coordinates, err = p.list(func() (interface{}, error) { return p.list(p.positions) })
+	case "GEOMETRYCOLLECTION":
+		var parts []*geoJSONGeometry
+		parts, err = p.collection()
+		g.Geometries = parts
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// position parses one coordinate tuple: x y [z [m]]. SQL Server writes NULL
+// for a missing Z when an M value follows.
+func (p *wktParser) position() ([]float64, error) {
+	var pos []float64
+	for i := 0; p.tok != "" && p.tok != "," && p.tok != ")"; i++ {
+		if i < 3 && !strings.EqualFold(p.tok, "NULL") {
+			f, err := strconv.ParseFloat(p.tok, 64)
+			if err != nil || len(pos) != i {
+				return nil, p.errorf()
+			}
+			pos = append(pos, f)
+		}
+		p.next()
+	}
+	if len(pos) < 2 {
+		return nil, p.errorf()
+	}
+	return pos, nil
+}
+
+// positions parses "(x y, x y, ...)" or EMPTY. MULTIPOINT members may also
+// be written in parentheses, as in "((x y), (x y))".
+func (p *wktParser) positions() (interface{}, error) {
+	coords := [][]float64{}
+	if p.empty() {
+		return coords, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		wrapped := p.tok == "("
+		if wrapped {
+			p.next()
+		}
+		pos, err := p.position()
+		if err != nil {
+			return nil, err
+		}
+		if wrapped {
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+		coords = append(coords, pos)
+		if p.tok != "," {
+			break
+		}
+		p.next()
+	}
+	return coords, p.expect(")")
+}
+
+// list parses "(item, item, ...)" or EMPTY, with each item parsed by item.
+func (p *wktParser) list(item func() (interface{}, error)) (interface{}, error) {
+	items := []interface{}{}
+	if p.empty() {
+		return items, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		v, err := item()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if p.tok != "," {
+			break
+		}
+		p.next()
+	}
+	return items, p.expect(")")
+}
+
+func (p *wktParser) collection() ([]*geoJSONGeometry, error) {
+	parts := []*geoJSONGeometry{}
+	if p.empty() {
+		return parts, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		g, err := p.geometry()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, g)
+		if p.tok != "," {
+			break
+		}
+		p.next()
+	}
+	return parts, p.expect(")")
+}
@@ -0,0 +1,79 @@
+package dbexport
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWKTToGeoJSON(t *testing.T) {
+	cases := []struct {
+		wkt  string
+		want string
+	}{
+		{"POINT (-122.35 47.65)", `{"type":"Point","coordinates":[-122.35,47.65]}`},
+		{"POINT (1 2 3 4)", `{"type":"Point","coordinates":[1,2,3]}`},
+		{"POINT (1 2 NULL 4)", `{"type":"Point","coordinates":[1,2]}`},
+		{"POINT Z (1 2 3)", `{"type":"Point","coordinates":[1,2,3]}`},
+		{"POINT EMPTY", `{"type":"Point","coordinates":[]}`},
+		{"LINESTRING (0 0, 1 1, 2 0)", `{"type":"LineString","coordinates":[[0,0],[1,1],[2,0]]}`},
+		{"POLYGON ((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))", `{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]}`},
+		{"MULTIPOINT ((1 2), (3 4))", `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`},
+		{"MULTIPOINT (1 2, 3 4)", `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`},
+		{"MULTILINESTRING ((0 0, 1 1), (2 2, 3 3))", `{"type":"MultiLineString","coordinates":[[[0,0],[1,1]],[[2,2],[3,3]]]}`},
+		{"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`},
+		{"GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (0 0, 1 1))", `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[0,0],[1,1]]}]}`},
+		{"GEOMETRYCOLLECTION EMPTY", `{"type":"GeometryCollection","geometries":[]}`},
+	}
+	for _, c := range cases {
+		g, err := wktToGeoJSON(c.wkt)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.wkt, err)
+			continue
+		}
+		got, _ := json.Marshal(g)
+		if string(got) != c.want {
+			t.Errorf("%s: got %s, want %s", c.wkt, got, c.want)
+		}
+	}
+	for _, bad := range []string{"", "POINT", "POINT (1)", "POINT (1 2", "POINT (1 2) x", "LINESTRING (0 0,)", "CIRCULARSTRING (0 0, 1 1, 2 0)", "FULLGLOBE"} {
+		if _, err := wktToGeoJSON(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestFileWriter_Spatial(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "INT"}, {Name: "shape", DatabaseType: "GEOGRAPHY", Nullable: true}}
+	data := [][]interface{}{{int64(1), "POINT (-122.35 47.65)"}, {int64(2), nil}}
+	for format, want := range map[string]string{
+		"csv":     "id,shape\n1,POINT (-122.35 47.65)\n2,\n",
+		"json":    `[{"id":1,"shape":{"type":"Point","coordinates":[-122.35,47.65]}},{"id":2,"shape":null}]`,
+		"geojson": `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[-122.35,47.65]},"properties":{"id":1}},{"type":"Feature","geometry":null,"properties":{"id":2}}]}`,
+	} {
+		out := filepath.Join(t.TempDir(), "out."+format)
+		w := newFileWriter(WriterOptions{Table: "t", Output: out}, format)
+		rows := &staticRows{cols: columnNames(cols), data: data}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+			t.Fatalf("%s: export failed: %v", format, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q (err: %v)", format, got, want, err)
+		}
+	}
+}
+
+func TestFileWriter_BadWKTIsRowError(t *testing.T) {
+	cols := []Column{{Name: "shape", DatabaseType: "GEOMETRY"}}
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{"CIRCULARSTRING (0 0, 1 1, 2 0)"}, {"POINT (1 2)"}}}
+	out := filepath.Join(t.TempDir(), "out.json")
+	w := newFileWriter(WriterOptions{Table: "t", Output: out}, "json")
+	run := exportRun{rowErrs: newRowErrorHandler(RowErrorSkip, filepath.Join(t.TempDir(), "rejects.jsonl"))}
+	res, err := runExport(context.Background(), rows, cols, w, run)
+	if err != nil || res.RowsWritten != 1 || res.RowsRejected != 1 {
+		t.Errorf("expected 1 row written and 1 rejected, got %+v (err: %v)", res, err)
+	}
+}
@@ -0,0 +1,60 @@
+package dbexport
+
+import (
+	"getmssql/i18n"
+	"strings"
+)
+
+// TSVEscaping selects how the tsv format writes values that contain tabs,
+// line breaks or backslashes.
+type TSVEscaping string
+
+const (
+	// TSVText follows the PostgreSQL text format: tab, line feed, carriage
+	// return and backslash are written as \t, \n, \r and \\, and NULL as \N.
+	// It is the default.
+	TSVText TSVEscaping = "text"
+	// TSVIANA follows the IANA text/tab-separated-values type: values are
+	// written as they are and NULL as an empty field. A value containing a
+	// tab or a line break cannot be represented and fails its row.
+	TSVIANA TSVEscaping = "iana"
+)
+
+// ParseTSVEscaping validates a TSV escaping as given on the command line.
+// The empty string selects TSVText.
+func ParseTSVEscaping(s string) (TSVEscaping, error) {
+	switch e := TSVEscaping(s); e {
+	case "":
+		return TSVText, nil
+	case TSVText, TSVIANA:
+		return e, nil
+	}
+	return "", i18n.Errorf("tsv.invalid_escaping", s)
+}
+
+// tsvEscaper escapes the PostgreSQL text format special characters. Other
+// control characters are left as they are, which PostgreSQL reads back.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvNull is the text format marker for NULL.
+const tsvNull = `\N`
+
+// tsvFields renders a row as TSV fields. Values are not checked for TSVIANA,
+// see fileWriter.prepareRow.
+func tsvFields(escaping TSVEscaping, vals []interface{}) []string {
+	if escaping == TSVIANA {
+		return formatDelimitedValues(vals, "")
+	}
+	fields := formatDelimitedValues(vals, tsvNull)
+	for i, field := range fields {
+		if vals[i] != nil {
+			fields[i] = tsvEscaper.Replace(field)
+		}
+	}
+	return fields
+}
+
+// ianaTSVSafe reports whether s can be written as an IANA TSV field.
+func ianaTSVSafe(s string) bool {
+	return !strings.ContainsAny(s, "\t\r\n")
+}
@@ -0,0 +1,158 @@
+package dbexport
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readTSV parses TSV written with escaping e the way a reader of that
+// convention would, returning nil for NULL fields.
+func readTSV(t *testing.T, text string, e TSVEscaping) [][]*string {
+	t.Helper()
+	var records [][]*string
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		var record []*string
+		for _, field := range strings.Split(sc.Text(), "\t") {
+			field := field
+			switch {
+			case e == TSVIANA:
+			case field == `\N`:
+				record = append(record, nil)
+				continue
+			default:
+				var b strings.Builder
+				for i := 0; i < len(field); i++ {
+					if field[i] != '\\' {
+						b.WriteByte(field[i])
+						continue
+					}
+					if i++; i == len(field) {
+						t.Fatalf("dangling backslash in %q", field)
+					}
+					switch field[i] {
+					case 't':
+						b.WriteByte('\t')
+					case 'n':
+						b.WriteByte('\n')
+					case 'r':
+						b.WriteByte('\r')
+					case '\\':
+						b.WriteByte('\\')
+					default:
+						t.Fatalf("unknown escape \\%c in %q", field[i], field)
+					}
+				}
+				field = b.String()
+			}
+			record = append(record, &field)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// writeTSV exports data with escaping e and returns the file contents.
+func writeTSV(t *testing.T, e TSVEscaping, cols []Column, data [][]interface{}) (string, error) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out.tsv")
+	w := newFileWriter(WriterOptions{Table: "t", Output: out, TSV: e}, "tsv")
+	rows := &staticRows{cols: columnNames(cols), data: data}
+	run := exportRun{rowErrs: newRowErrorHandler(RowErrorSkip, "")}
+	if _, err := runExport(context.Background(), rows, cols, w, run); err != nil {
+		return "", err
+	}
+	got, err := os.ReadFile(out)
+	return string(got), err
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestTSV_TextRoundTrip(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "INT"}, {Name: "note", DatabaseType: "NVARCHAR", Nullable: true}}
+	notes := []interface{}{"plain", "a\tb", "two\nlines", "cr\r\nlf", `C:\temp\new`, `\N`, `\t`, "", nil, "trailing\\", "ñandú ☃"}
+	var data [][]interface{}
+	want := [][]*string{{strPtr("id"), strPtr("note")}}
+	for i, note := range notes {
+		id := string(rune('a' + i))
+		data = append(data, []interface{}{id, note})
+		var s *string
+		if note != nil {
+			s = strPtr(note.(string))
+		}
+		want = append(want, []*string{strPtr(id), s})
+	}
+	text, err := writeTSV(t, TSVText, cols, data)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if lines := strings.Count(text, "\n"); lines != len(want) {
+		t.Errorf("got %d lines, want one per record (%d):\n%s", lines, len(want), text)
+	}
+	got := readTSV(t, text, TSVText)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip mismatch:\n got  %s\n want %s", formatRecords(got), formatRecords(want))
+	}
+}
+
+func TestTSV_TextEscapes(t *testing.T) {
+	cols := []Column{{Name: "a\tb", DatabaseType: "NVARCHAR"}, {Name: "c", DatabaseType: "NVARCHAR"}}
+	text, err := writeTSV(t, TSVText, cols, [][]interface{}{{"x\ty\\z\r\n", nil}, {"", `\N`}})
+	want := "a\\tb\tc\n" + "x\\ty\\\\z\\r\\n\t\\N\n" + "\t\\\\N\n"
+	if err != nil || text != want {
+		t.Errorf("got %q, want %q (err: %v)", text, want, err)
+	}
+}
+
+func TestTSV_IANA(t *testing.T) {
+	cols := []Column{{Name: "id", DatabaseType: "INT"}, {Name: "note", DatabaseType: "NVARCHAR", Nullable: true}}
+	data := [][]interface{}{{"1", `C:\temp`}, {"2", "a\tb"}, {"3", nil}, {"4", "two\nlines"}}
+	text, err := writeTSV(t, TSVIANA, cols, data)
+	want := "id\tnote\n1\tC:\\temp\n3\t\n"
+	if err != nil || text != want {
+		t.Errorf("got %q, want %q (err: %v)", text, want, err)
+	}
+	got := readTSV(t, text, TSVIANA)
+	if len(got) != 3 || *got[1][1] != `C:\temp` || *got[2][1] != "" {
+		t.Errorf("read back %s", formatRecords(got))
+	}
+
+	if _, err := writeTSV(t, TSVIANA, []Column{{Name: "a\tb"}}, nil); err == nil || !strings.Contains(err.Error(), "IANA TSV") {
+		t.Errorf("tab in a column name: got %v, want an IANA TSV error", err)
+	}
+}
+
+func TestParseTSVEscaping(t *testing.T) {
+	for in, want := range map[string]TSVEscaping{"": TSVText, "text": TSVText, "iana": TSVIANA} {
+		if got, err := ParseTSVEscaping(in); err != nil || got != want {
+			t.Errorf("ParseTSVEscaping(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseTSVEscaping("csv"); err == nil {
+		t.Error("expected an error for an unknown escaping")
+	}
+}
+
+func formatRecords(records [][]*string) string {
+	var b strings.Builder
+	for _, record := range records {
+		b.WriteString("[")
+		for i, field := range record {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			if field == nil {
+				b.WriteString("NULL")
+			} else {
+				b.WriteString(strings.ReplaceAll(strings.ReplaceAll(*field, "\t", `\t`), "\n", `\n`))
+			}
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
@@ -1,5 +1,10 @@
 package dbexport
 
+import (
+	"database/sql"
+	"strings"
+)
+
 // Rows is a minimal interface for *sql.Rows and test wrappers
 // Used for dependency injection and testability in output writers.
 type Rows interface {
@@ -9,3 +14,82 @@ type Rows interface {
 	Close() error
 	Err() error
 }
+
+// Column describes one column of the exported result set.
+// Type details are empty when the driver does not report them.
+type Column struct {
+	Name         string
+	DatabaseType string // driver type name, e.g. "INT" or "NVARCHAR"
+	Nullable     bool
+	Length       int64
+	Precision    int64
+	Scale        int64
+
+	override *ColumnType // set by ExportOptions.ColumnTypes
+}
+
+// columnTyper is implemented by *sql.Rows.
+type columnTyper interface {
+	ColumnTypes() ([]*sql.ColumnType, error)
+}
+
+// resolveColumns builds the column schema for rows, using the driver's column
+// type information when rows provides it and falling back to names only.
+func resolveColumns(rows Rows, names []string) []Column {
+	cols := make([]Column, len(names))
+	for i, name := range names {
+		cols[i] = Column{Name: name, Nullable: true}
+	}
+	typer, ok := rows.(columnTyper)
+	if !ok {
+		return cols
+	}
+	types, err := typer.ColumnTypes()
+	if err != nil || len(types) != len(cols) {
+		return cols
+	}
+	for i, ct := range types {
+		cols[i].DatabaseType = ct.DatabaseTypeName()
+		if nullable, ok := ct.Nullable(); ok {
+			cols[i].Nullable = nullable
+		}
+		if length, ok := ct.Length(); ok {
+			cols[i].Length = length
+		}
+		if precision, scale, ok := ct.DecimalSize(); ok {
+			cols[i].Precision = precision
+			cols[i].Scale = scale
+		}
+	}
+	return cols
+}
+
+// decimalPrecision returns the precision of a decimal column. MONEY and
+// SMALLMONEY have a fixed precision the driver does not report.
+func decimalPrecision(col Column) int64 {
+	switch strings.ToUpper(col.DatabaseType) {
+	case "MONEY":
+		return 19
+	case "SMALLMONEY":
+		return 10
+	}
+	return col.Precision
+}
+
+// decimalScale returns the scale of a decimal column, see decimalPrecision.
+func decimalScale(col Column) int64 {
+	switch strings.ToUpper(col.DatabaseType) {
+	case "MONEY", "SMALLMONEY":
+		return 4
+	}
+	return col.Scale
+}
+
+// columnNames returns the names of cols in order.
+func columnNames(cols []Column) []string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.Name
+	}
+	return names
+}
@@ -0,0 +1,282 @@
+package dbexport
+
+import (
+	"fmt"
+	"getmssql/i18n"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Target types of a ColumnType override.
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeDecimal  = "decimal"
+	TypeDate     = "date"
+	TypeDatetime = "datetime"
+	TypeBoolean  = "boolean"
+)
+
+// overrideDatabaseTypes maps each target type to the MSSQL type the column
+// is given, which selects its conversions and its SQLite and DuckDB DDL.
+var overrideDatabaseTypes = map[string]string{
+	TypeString:   "NVARCHAR",
+	TypeInt:      "BIGINT",
+	TypeDecimal:  "DECIMAL",
+	TypeDate:     "DATE",
+	TypeDatetime: "DATETIME2",
+	TypeBoolean:  "BIT",
+}
+
+// ColumnType overrides the type a column is exported as.
+type ColumnType struct {
+	// Type is one of TypeString, TypeInt, TypeDecimal, TypeDate,
+	// TypeDatetime and TypeBoolean.
+	Type string `yaml:"type" json:"type"`
+	// Format is the text format of date and datetime columns, with the
+	// values of WriterOptions.DatetimeFormat. It also parses text values.
+	Format string `yaml:"format" json:"format"`
+	// Style is the text written for boolean columns as "true/false", for
+	// example "yes/no" or "1/0". Empty writes true and false.
+	Style string `yaml:"style" json:"style"`
+	// Precision and Scale declare decimal columns in the database formats.
+	Precision int64 `yaml:"precision" json:"precision"`
+	Scale     int64 `yaml:"scale" json:"scale"`
+
+	source string // MSSQL type of the column before the override
+}
+
+// UnmarshalYAML accepts the type name alone as a shorthand, as in
+// "account_no: string".
+func (t *ColumnType) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		t.Type = node.Value
+		return nil
+	}
+	type plain ColumnType
+	return node.Decode((*plain)(t))
+}
+
+// validate checks the override of column name.
+func (t ColumnType) validate(name string) error {
+	if _, ok := overrideDatabaseTypes[t.Type]; !ok {
+		return i18n.Errorf("typesfile.bad_type", name, t.Type)
+	}
+	if t.Format != "" {
+		if t.Type != TypeDate && t.Type != TypeDatetime {
+			return i18n.Errorf("typesfile.bad_option", name, "format", t.Type)
+		}
+		if err := ValidateDatetimeFormat(t.Format); err != nil {
+			return i18n.Errorf("typesfile.column_error", name, err)
+		}
+	}
+	if t.Style != "" {
+		if t.Type != TypeBoolean {
+			return i18n.Errorf("typesfile.bad_option", name, "style", t.Type)
+		}
+		if _, _, ok := t.boolStyle(); !ok {
+			return i18n.Errorf("typesfile.bad_style", name, t.Style)
+		}
+	}
+	if (t.Precision != 0 || t.Scale != 0) && t.Type != TypeDecimal {
+		return i18n.Errorf("typesfile.bad_option", name, "precision", t.Type)
+	}
+	if t.Precision < 0 || t.Precision > 38 || t.Scale < 0 || (t.Precision > 0 && t.Scale > t.Precision) {
+		return i18n.Errorf("typesfile.bad_precision", name, t.Precision, t.Scale)
+	}
+	return nil
+}
+
+// boolStyle splits Style into the text for true and for false.
+func (t ColumnType) boolStyle() (yes, no string, ok bool) {
+	yes, no, ok = strings.Cut(t.Style, "/")
+	return yes, no, ok && yes != "" && no != "" && yes != no && !strings.Contains(no, "/")
+}
+
+// ReadTypesFile reads column type overrides from a YAML or JSON file that
+// maps column names to a ColumnType or to a type name:
+//
+//	account_no: string
+//	opened: {type: date, format: "02/01/2006"}
+//	active: {type: boolean, style: yes/no}
+func ReadTypesFile(path string) (map[string]ColumnType, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, i18n.Errorf("typesfile.read_error", err)
+	}
+	// YAML is a superset of JSON, so one parser reads both
+	var types map[string]ColumnType
+	if err := yaml.Unmarshal(data, &types); err != nil {
+		return nil, i18n.Errorf("typesfile.parse_error", path, err)
+	}
+	for name, t := range types {
+		if err := t.validate(name); err != nil {
+			return nil, err
+		}
+	}
+	return types, nil
+}
+
+// applyColumnTypes gives the columns named in types their target type.
+// Column names match case-insensitively, as in MSSQL; naming a column that is
+// not exported is an error.
+func applyColumnTypes(cols []Column, types map[string]ColumnType) error {
+	byName := make(map[string]int, len(cols))
+	for i, col := range cols {
+		byName[strings.ToLower(col.Name)] = i
+	}
+	for name, t := range types {
+		if err := t.validate(name); err != nil {
+			return err
+		}
+		i, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return i18n.Errorf("typesfile.unknown_column", name)
+		}
+		col := &cols[i]
+		t.source = strings.ToUpper(col.DatabaseType)
+		if t.Type == TypeDecimal && t.Precision == 0 && isDecimalType(t.source) {
+			t.Precision, t.Scale = decimalPrecision(*col), decimalScale(*col)
+		}
+		col.DatabaseType = overrideDatabaseTypes[t.Type]
+		col.Precision, col.Scale = t.Precision, t.Scale
+		col.override = &t
+	}
+	return nil
+}
+
+// coerceRow converts the values of overridden columns to their target type,
+// as the driver would send a column of that type: string, int64, decimal text
+// as []byte, time.Time or bool.
+func coerceRow(cols []Column, vals []interface{}) error {
+	for i, col := range cols {
+		if col.override == nil || vals[i] == nil {
+			continue
+		}
+		v, err := col.override.coerce(vals[i])
+		if err != nil {
+			return i18n.Errorf("values.column_error", col.Name, err)
+		}
+		vals[i] = v
+	}
+	return nil
+}
+
+// coerce converts a non-nil driver value to the target type.
+func (t *ColumnType) coerce(v interface{}) (interface{}, error) {
+	// Read values as text the way the file formats write the source type,
+	// but keep untyped text as it is rather than guessing numbers
+	text := func() string {
+		switch x := v.(type) {
+		case []byte:
+			if t.source == "" {
+				return string(x)
+			}
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64)
+		}
+		s, err := valueConverter{binary: BinaryHex}.convert(Column{DatabaseType: t.source}, v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		if b, ok := s.([]byte); ok {
+			return string(b)
+		}
+		return fmt.Sprint(s)
+	}
+	switch t.Type {
+	case TypeString:
+		return text(), nil
+	case TypeInt:
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+				return int64(n), nil
+			}
+		case bool:
+			if n {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		default:
+			if i, err := strconv.ParseInt(strings.TrimSpace(text()), 10, 64); err == nil {
+				return i, nil
+			}
+		}
+	case TypeDecimal:
+		s := strings.TrimSpace(text())
+		if b, ok := v.(bool); ok {
+			s = "0"
+			if b {
+				s = "1"
+			}
+		}
+		if jsonNumberRe.MatchString(s) {
+			return []byte(s), nil
+		}
+	case TypeDate, TypeDatetime:
+		if tm, ok := v.(time.Time); ok {
+			return tm, nil
+		}
+		if tm, ok := t.parseTime(strings.TrimSpace(text())); ok {
+			return tm, nil
+		}
+	case TypeBoolean:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case int64:
+			return b != 0, nil
+		}
+		s := strings.TrimSpace(text())
+		if yes, no, ok := t.boolStyle(); ok {
+			switch {
+			case strings.EqualFold(s, yes):
+				return true, nil
+			case strings.EqualFold(s, no):
+				return false, nil
+			}
+		}
+		switch strings.ToLower(s) {
+		case "1", "true", "t", "yes", "y":
+			return true, nil
+		case "0", "false", "f", "no", "n":
+			return false, nil
+		}
+	}
+	return nil, i18n.Errorf("typesfile.bad_value", fmt.Sprint(v), t.Type)
+}
+
+// parseTime parses text in the column's Format, or else as ISO 8601.
+func (t *ColumnType) parseTime(s string) (time.Time, bool) {
+	layouts := []string{time.RFC3339Nano, "2006-01-02T15:04:05.9999999", "2006-01-02 15:04:05.9999999", "2006-01-02"}
+	if t.Format != "" && t.Format != DatetimeISO && t.Format != DatetimeDateOnly {
+		layouts = append([]string{t.Format}, layouts...)
+	}
+	for _, layout := range layouts {
+		if tm, err := time.Parse(layout, s); err == nil {
+			return tm, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatBool returns the text of a boolean value in the column's Style, or
+// the value itself when there is none.
+func (t *ColumnType) formatBool(b bool) interface{} {
+	yes, no, ok := t.boolStyle()
+	switch {
+	case !ok:
+		return b
+	case b:
+		return yes
+	}
+	return no
+}
@@ -0,0 +1,183 @@
+package dbexport
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTypesFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadTypesFile(t *testing.T) {
+	want := map[string]ColumnType{
+		"account_no": {Type: TypeString},
+		"opened":     {Type: TypeDate, Format: "02/01/2006"},
+		"active":     {Type: TypeBoolean, Style: "yes/no"},
+		"amount":     {Type: TypeDecimal, Precision: 12, Scale: 2},
+	}
+	yamlFile := writeTypesFile(t, "types.yaml", `
+account_no: string
+opened: {type: date, format: "02/01/2006"}
+active:
+  type: boolean
+  style: yes/no
+amount: {type: decimal, precision: 12, scale: 2}
+`)
+	jsonFile := writeTypesFile(t, "types.json", `{
+  "account_no": "string",
+  "opened": {"type": "date", "format": "02/01/2006"},
+  "active": {"type": "boolean", "style": "yes/no"},
+  "amount": {"type": "decimal", "precision": 12, "scale": 2}
+}`)
+	for _, path := range []string{yamlFile, jsonFile} {
+		got, err := ReadTypesFile(path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v (err: %v), want %+v", filepath.Base(path), got, err, want)
+		}
+	}
+
+	for content, msg := range map[string]string{
+		"a: uuid":                                    "unknown type",
+		"a: {type: int, format: iso}":                "does not apply",
+		"a: {type: boolean, style: yes}":             "invalid boolean style",
+		"a: {type: date, format: yyyy}":              "invalid datetime format",
+		"a: {type: decimal, precision: 50}":          "invalid decimal precision",
+		"a: {type: string, precision: 5}":            "does not apply",
+		"a: [string]":                                "error parsing types file",
+		"a: {type: decimal, precision: 4, scale: 6}": "invalid decimal precision",
+	} {
+		if _, err := ReadTypesFile(writeTypesFile(t, "bad.yaml", content)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: expected error containing %q, got %v", content, msg, err)
+		}
+	}
+	if _, err := ReadTypesFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestColumnType_Coerce(t *testing.T) {
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		typ    ColumnType
+		source string
+		in     interface{}
+		want   interface{}
+	}{
+		// Untyped text is never guessed as a number
+		{ColumnType{Type: TypeString}, "", []byte("0001234"), "0001234"},
+		{ColumnType{Type: TypeString}, "INT", int64(42), "42"},
+		{ColumnType{Type: TypeString}, "FLOAT", 1e21, "1000000000000000000000"},
+		{ColumnType{Type: TypeString}, "DATE", day, "2024-02-29"},
+		{ColumnType{Type: TypeInt}, "CHAR", " 0042 ", int64(42)},
+		{ColumnType{Type: TypeInt}, "FLOAT", float64(3), int64(3)},
+		{ColumnType{Type: TypeInt}, "BIT", true, int64(1)},
+		{ColumnType{Type: TypeDecimal}, "VARCHAR", "12.50", []byte("12.50")},
+		{ColumnType{Type: TypeDecimal}, "MONEY", []byte("0.1000"), []byte("0.1000")},
+		{ColumnType{Type: TypeDate, Format: "02/01/2006"}, "VARCHAR", "29/02/2024", day},
+		{ColumnType{Type: TypeDatetime}, "VARCHAR", "2024-02-29T00:00:00", day},
+		{ColumnType{Type: TypeBoolean}, "CHAR", "Y", true},
+		{ColumnType{Type: TypeBoolean, Style: "sí/no"}, "NVARCHAR", "No", false},
+		{ColumnType{Type: TypeBoolean}, "INT", int64(0), false},
+	}
+	for _, c := range cases {
+		c.typ.source = c.source
+		got, err := c.typ.coerce(c.in)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s from %s %#v: got %#v (err: %v), want %#v", c.typ.Type, c.source, c.in, got, err, c.want)
+		}
+	}
+	for _, c := range []struct {
+		typ ColumnType
+		in  interface{}
+	}{
+		{ColumnType{Type: TypeInt}, "12a"},
+		{ColumnType{Type: TypeInt}, 1.5},
+		{ColumnType{Type: TypeDecimal}, "1,5"},
+		{ColumnType{Type: TypeDate}, "yesterday"},
+		{ColumnType{Type: TypeBoolean}, "maybe"},
+	} {
+		if _, err := c.typ.coerce(c.in); err == nil {
+			t.Errorf("%s from %#v: expected error", c.typ.Type, c.in)
+		}
+	}
+}
+
+func TestApplyColumnTypes(t *testing.T) {
+	cols := []Column{{Name: "Account_No", DatabaseType: "CHAR"}, {Name: "amount", DatabaseType: "MONEY"}, {Name: "flag", DatabaseType: "CHAR"}}
+	err := applyColumnTypes(cols, map[string]ColumnType{"account_no": {Type: TypeString}, "amount": {Type: TypeDecimal}, "flag": {Type: TypeBoolean}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cols[0].DatabaseType != "NVARCHAR" || cols[1].DatabaseType != "DECIMAL" || cols[1].Precision != 19 || cols[1].Scale != 4 || cols[2].DatabaseType != "BIT" {
+		t.Errorf("unexpected columns: %+v", cols)
+	}
+	if err := applyColumnTypes(cols, map[string]ColumnType{"missing": {Type: TypeInt}}); err == nil || !strings.Contains(err.Error(), "not exported") {
+		t.Errorf("expected unknown column error, got %v", err)
+	}
+}
+
+func TestColumnTypes_FileFormats(t *testing.T) {
+	cols := []Column{{Name: "account_no"}, {Name: "opened", DatabaseType: "VARCHAR"}, {Name: "active", DatabaseType: "CHAR"}}
+	types := map[string]ColumnType{
+		"account_no": {Type: TypeString},
+		"opened":     {Type: TypeDate, Format: "02/01/2006"},
+		"active":     {Type: TypeBoolean, Style: "yes/no"},
+	}
+	if err := applyColumnTypes(cols, types); err != nil {
+		t.Fatal(err)
+	}
+	data := [][]interface{}{{[]byte("0001234"), "2024-02-29", "1"}}
+	for format, want := range map[string]string{
+		"json": `[{"account_no":"0001234","active":"yes","opened":"29/02/2024"}]`,
+		"csv":  "account_no,opened,active\n0001234,29/02/2024,yes\n",
+	} {
+		out := filepath.Join(t.TempDir(), "out."+format)
+		w := newFileWriter(WriterOptions{Table: "t", Output: out}, format)
+		rows := &staticRows{cols: columnNames(cols), data: data}
+		if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+			t.Fatalf("%s: export failed: %v", format, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q (err: %v)", format, got, want, err)
+		}
+	}
+}
+
+func TestColumnTypes_SQLiteDDL(t *testing.T) {
+	cols := []Column{{Name: "account_no", DatabaseType: "CHAR"}, {Name: "qty", DatabaseType: "VARCHAR"}, {Name: "active", DatabaseType: "CHAR"}}
+	if err := applyColumnTypes(cols, map[string]ColumnType{"account_no": {Type: TypeString}, "qty": {Type: TypeInt}, "active": {Type: TypeBoolean}}); err != nil {
+		t.Fatal(err)
+	}
+	dbFile := filepath.Join(t.TempDir(), "types.sqlite3")
+	w := newSQLiteWriter(WriterOptions{Table: "accounts", Output: dbFile})
+	rows := &staticRows{cols: columnNames(cols), data: [][]interface{}{{"0001234", "7", "Y"}}}
+	if _, err := runExport(context.Background(), rows, cols, w, exportRun{}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var types, account string
+	var qty, active int64
+	if err := db.QueryRow(`SELECT group_concat(type, ',') FROM pragma_table_info('accounts')`).Scan(&types); err != nil || types != "TEXT,INTEGER,INTEGER" {
+		t.Errorf("unexpected column types %q (err: %v)", types, err)
+	}
+	if err := db.QueryRow(`SELECT account_no, qty, active FROM accounts`).Scan(&account, &qty, &active); err != nil || account != "0001234" || qty != 7 || active != 1 {
+		t.Errorf("unexpected row %q %d %d (err: %v)", account, qty, active, err)
+	}
+}
@@ -0,0 +1,166 @@
+package dbexport
+
+import (
+	"context"
+	"fmt"
+	"getmssql/i18n"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Writer receives the rows of an export.
+//
+// Open is called once with the resolved column schema before any data is
+// written. WriteBatch is then called with batches of converted row values, in
+// column order. Close is called exactly once after a successful Open, also when
+// the export stops early, and must flush and release everything the writer holds.
+type Writer interface {
+	Open(ctx context.Context, cols []Column) error
+	WriteBatch(ctx context.Context, rows [][]interface{}) error
+	Close() error
+}
+
+// Locator is implemented by writers that can describe where their output went,
+// for example a file name or a database file and table.
+type Locator interface {
+	Location() string
+}
+
+// ByteCounter is implemented by writers that can report the size of their output.
+type ByteCounter interface {
+	BytesWritten() int64
+}
+
+// NativeValuer is implemented by writers that want each value as returned by
+// the driver (time.Time, []byte for DECIMAL and binary columns, ...) instead of
+// the converted values the file formats receive, when NativeValues returns true.
+type NativeValuer interface {
+	NativeValues() bool
+}
+
+// rowPreparer is implemented by writers that prepare values themselves: the
+// database writers convert native values, the JSON writers turn WKT into
+// GeoJSON. writeRows calls prepareRow on each row before batching it, so that
+// a value the writer cannot store is handled like any other failed row.
+type rowPreparer interface {
+	prepareRow(row []interface{}) error
+}
+
+// WriterOptions carries the settings a WriterFactory needs to build a Writer.
+type WriterOptions struct {
+	// Table is the source table name; writers derive file and table names from it.
+	Table string
+	// Output overrides the output path: the file for file formats, the
+	// database file for database formats. Empty selects the format's default.
+	Output string
+	// Overwrite decides what happens when the output target already exists.
+	Overwrite OverwritePolicy
+	// Types selects how database writers declare column types.
+	// The zero value behaves like TypesNative.
+	Types TypeMode
+	// DatetimeFormat sets how dates and times are written as text:
+	// DatetimeISO (the default), DatetimeDateOnly or a Go time layout.
+	DatetimeFormat string
+	// TimeZone, when set, converts values that carry an offset
+	// (datetimeoffset) to this zone before they are written as text.
+	TimeZone *time.Location
+	// BinaryEncoding sets how binary values are written as text. The zero
+	// value behaves like BinaryAuto.
+	BinaryEncoding BinaryEncoding
+	// Gzip compresses the output of the file formats. The default file name
+	// gets a .gz suffix.
+	Gzip bool
+	// CSV is the dialect of the csv format. The zero value writes standard
+	// comma separated values; see CSVDialects.
+	CSV CSVDialect
+	// Arrow configures the arrow format.
+	Arrow ArrowOptions
+	// Avro configures the avro format.
+	Avro AvroOptions
+	// Parquet configures the parquet format.
+	Parquet ParquetOptions
+	// SQL configures the sql format.
+	SQL SQLOptions
+	// TSV sets how the tsv format escapes values. The zero value behaves
+	// like TSVText.
+	TSV TSVEscaping
+}
+
+// WriterFactory builds a new Writer for a single export.
+type WriterFactory func(opts WriterOptions) Writer
+
+// ErrAborted is returned by Writer.Open when the user declines to continue,
+// for example at an overwrite prompt. The export then ends without an error.
+var ErrAborted error = i18n.NewError("err.aborted")
+
+// ErrTargetExists is returned by Writer.Open when the output target exists
+// and the overwrite policy is OverwriteNever.
+var ErrTargetExists error = i18n.NewError("err.target_exists")
+
+var (
+	formatsMu sync.RWMutex
+	formats   = make(map[string]WriterFactory)
+)
+
+// RegisterFormat makes an output format available under the given name.
+// It panics if the name is empty, the factory is nil, or the name is already
+// registered, mirroring database/sql.Register.
+func RegisterFormat(name string, factory WriterFactory) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if name == "" {
+		panic("dbexport: RegisterFormat name is empty")
+	}
+	if factory == nil {
+		panic("dbexport: RegisterFormat factory is nil for format " + name)
+	}
+	if _, dup := formats[name]; dup {
+		panic("dbexport: RegisterFormat called twice for format " + name)
+	}
+	formats[name] = factory
+}
+
+// Formats returns the sorted names of all registered output formats.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewWriter returns a Writer for the named format.
+func NewWriter(format string, opts WriterOptions) (Writer, error) {
+	formatsMu.RLock()
+	factory, ok := formats[format]
+	formatsMu.RUnlock()
+	if !ok {
+		return nil, i18n.Errorf("format.unknown", format, strings.Join(Formats(), ", "))
+	}
+	return factory(opts), nil
+}
+
+// confirmOverwrite applies policy to an output target that already exists.
+// exists describes the target (e.g. "Table 'foo' already exists in output.sqlite3")
+// and question is asked when prompting. It returns ErrAborted if the user declines.
+func confirmOverwrite(policy OverwritePolicy, exists, question string, scanlnFn func(...interface{}) (int, error)) error {
+	switch policy {
+	case OverwriteAlways:
+		return nil
+	case OverwriteNever:
+		return fmt.Errorf("%s: %w", exists, ErrTargetExists)
+	}
+	fmt.Print(i18n.T("prompt.confirm", exists, question))
+	var response string
+	scanlnFn(&response)
+	if !i18n.IsYes(response) {
+		fmt.Println(i18n.T("prompt.aborted"))
+		return ErrAborted
+	}
+	return nil
+}
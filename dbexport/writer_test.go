@@ -0,0 +1,72 @@
+package dbexport
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFormats_BuiltIns(t *testing.T) {
+	got := strings.Join(Formats(), ",")
+	if got != "arrow,avro,csv,duckdb,geojson,json,jsonl,parquet,pgcopy,sql,sqlite3,tsv,xlsx" {
+		t.Errorf("unexpected built-in formats: %s", got)
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	w, err := NewWriter("csv", WriterOptions{Table: "t"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := w.(*fileWriter); !ok {
+		t.Errorf("expected *fileWriter for csv, got %T", w)
+	}
+
+	_, err = NewWriter("nope", WriterOptions{})
+	if err == nil || !strings.Contains(err.Error(), `unknown format "nope"`) || !strings.Contains(err.Error(), "sqlite3") {
+		t.Errorf("expected unknown format error listing formats, got: %v", err)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	RegisterFormat("test-custom", func(opts WriterOptions) Writer { return &stubWriter{} })
+	defer func() {
+		formatsMu.Lock()
+		delete(formats, "test-custom")
+		formatsMu.Unlock()
+	}()
+
+	w, err := NewWriter("test-custom", WriterOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Open(context.Background(), nil); err != nil {
+		t.Errorf("unexpected error from custom writer: %v", err)
+	}
+
+	for name, fn := range map[string]func(){
+		"duplicate":   func() { RegisterFormat("test-custom", func(WriterOptions) Writer { return &stubWriter{} }) },
+		"empty name":  func() { RegisterFormat("", func(WriterOptions) Writer { return &stubWriter{} }) },
+		"nil factory": func() { RegisterFormat("test-nil", nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %s", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestParseOverwritePolicy(t *testing.T) {
+	for _, s := range []string{"", "prompt", "always", "never"} {
+		if p, err := ParseOverwritePolicy(s); err != nil || string(p) != s {
+			t.Errorf("ParseOverwritePolicy(%q) = %q, %v", s, p, err)
+		}
+	}
+	if _, err := ParseOverwritePolicy("sometimes"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
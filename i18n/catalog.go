@@ -0,0 +1,260 @@
+package i18n
+
+// catalogs maps a language code to its messages. Every key must exist in the
+// English catalog; other catalogs may leave keys out and fall back to English.
+var catalogs = map[string]map[string]string{
+	English: en,
+	"es":    es,
+}
+
+var en = map[string]string{
+	"i18n.unsupported": "unsupported language %q (available: %s)",
+
+	// Prompts
+	"prompt.confirm": "%s. %s (y/N): ",
+	"prompt.yes":     "y",
+	"prompt.aborted": "Aborted by user.",
+
+	// Progress
+	"progress.start_total":      "Starting download of table '%s'... (total rows: %d)",
+	"progress.start":            "Starting download of table '%s'...",
+	"progress.bar":              "%5.1f%%  %d/%d rows  %.0f rows/s  ETA %s",
+	"progress.bar_rows":         "Downloaded %d rows  %.0f rows/s",
+	"progress.line_start_total": "Starting download of table '%s' (total rows: %d)",
+	"progress.line_start":       "Starting download of table '%s'",
+	"progress.line":             "Downloaded %d/%d rows (%.1f%%), %.0f rows/s, ETA %s",
+	"progress.line_rows":        "Downloaded %d rows, %.0f rows/s",
+	"progress.stopped":          "Download stopped after %d rows",
+	"progress.done":             "Total rows downloaded: %d",
+
+	// Listing tables and fields
+	"tables.header":              "Tables in the database:",
+	"tables.query_error":         "error querying tables: %w",
+	"tables.scan_error":          "error scanning table name: %w",
+	"fields.header":              "Fields in table '%s':",
+	"fields.columns":             "Column Name\tType\tNullable",
+	"fields.query_error":         "error querying fields: %w",
+	"fields.scan_error":          "error scanning field: %w",
+	"fields.none_found":          "no fields found for table '%s': %w",
+	"rows.error":                 "row error: %w",
+	"rows.scan_error":            "error scanning row: %w",
+	"rows.row_error":             "row %d: %v",
+	"rows.skipped":               "warning: skipping %v",
+	"rows.invalid_policy":        "invalid row error policy %q (use fail, skip or log)",
+	"rejects.create_error":       "error creating reject file: %w",
+	"rejects.write_error":        "error writing reject file: %w",
+	"values.bad_uuid":            "uniqueidentifier has %d bytes, want 16",
+	"values.column_error":        "column %s: %w",
+	"values.bad_datetime_format": "invalid datetime format %q (use iso, date or a Go time layout such as 2006-01-02 15:04:05)",
+	"values.bad_timezone":        "invalid time zone %q: %v",
+	"spatial.bad_wkt":            "invalid WKT at offset %d: %q",
+	"spatial.unsupported_type":   "%s cannot be written as GeoJSON",
+
+	// Downloading
+	"download.count_error":        "could not get total row count: %w",
+	"download.query_error":        "error querying table rows: %w",
+	"download.columns_error":      "error getting columns: %w",
+	"download.cancelled":          "export cancelled: %w",
+	"download.table_name_parts":   "table name %q has more than a schema and a name; connect to its database and use schema.table",
+	"download.done":               "Table '%s' data written to %s in %s",
+	"download.rejected":           "%d rows rejected, see %s",
+	"download.rejected_count":     "%d rows rejected",
+	"fieldsfile.read_error":       "error reading fields file: %w",
+	"fieldsfile.empty":            "no fields found in file: %s",
+	"typesfile.read_error":        "error reading types file: %w",
+	"typesfile.parse_error":       "error parsing types file %s: %v",
+	"typesfile.bad_type":          "types file: column %s: unknown type %q (use string, int, decimal, date, datetime or boolean)",
+	"typesfile.bad_option":        "types file: column %s: %s does not apply to type %s",
+	"typesfile.bad_style":         "types file: column %s: invalid boolean style %q (use true/false text such as yes/no)",
+	"typesfile.bad_precision":     "types file: column %s: invalid decimal precision %d and scale %d",
+	"typesfile.column_error":      "types file: column %s: %w",
+	"typesfile.unknown_column":    "types file: column %s is not exported",
+	"typesfile.bad_value":         "cannot convert %q to %s",
+	"format.unknown":              "unknown format %q (available: %s)",
+	"overwrite.invalid":           "invalid overwrite policy %q (use prompt, always or never)",
+	"types.invalid_mode":          "invalid column types %q (use native or text)",
+	"binary.invalid_encoding":     "invalid binary encoding %q (use auto, base64 or hex)",
+	"csv.unknown_dialect":         "unknown CSV dialect %q (available: %s)",
+	"csv.bad_delimiter":           "invalid CSV delimiter %q (use one character that is not a line break)",
+	"csv.bad_quote":               "invalid CSV quote %q (use one character other than the delimiter or a line break)",
+	"csv.quote_conflict":          "a CSV dialect cannot both quote every field and quote none",
+	"csv.bad_line_terminator":     "invalid CSV line terminator %q (use lf or crlf)",
+	"tsv.invalid_escaping":        "invalid TSV escaping %q (use text or iana)",
+	"tsv.unescapable":             "IANA TSV cannot hold a tab or line break",
+	"parquet.invalid_compression": "invalid Parquet compression %q (use snappy, zstd, gzip or none)",
+	"arrow.bad_value":             "cannot store %v as %v",
+	"arrow.null_value":            "NULL in a column that is not nullable",
+	"xlsx.cell_too_long":          "text longer than the %d characters of an Excel cell",
+	"sql.invalid_dialect":         "invalid SQL dialect %q (use mssql, postgres, mysql or sqlite)",
+	"sql.bad_insert_rows":         "invalid number of rows per INSERT %d for %s (SQL Server accepts at most 1000)",
+	"sql.nul_character":           "%s text cannot hold a NUL character",
+	"avro.invalid_codec":          "invalid Avro codec %q (use deflate, snappy, zstd or none)",
+	"avro.bad_value":              "cannot store %v as Avro %v",
+	"avro.null_value":             "NULL in a column that is not nullable",
+
+	// Writers
+	"writer.no_columns":      "columns is empty",
+	"writer.empty_column":    "column name at index %d is empty",
+	"file.exists":            "File '%s' already exists",
+	"file.overwrite":         "Overwrite?",
+	"file.create_error":      "error creating output file: %w",
+	"file.write_error":       "error writing output file: %w",
+	"db.open_error":          "error opening %s database: %w",
+	"db.exists_check_error":  "error checking if table exists in %s: %w",
+	"db.table_exists":        "Table '%s' already exists in %s",
+	"db.recreate":            "Delete and recreate?",
+	"db.drop_error":          "error dropping table in %s: %w",
+	"db.table_dropped":       "Table '%s' dropped.",
+	"db.create_error":        "error creating table in %s: %w",
+	"db.begin_error":         "error starting %s transaction: %w",
+	"db.prepare_error":       "error preparing %s statement: %w",
+	"db.insert_error":        "error inserting row into %s: %w",
+	"db.commit_error":        "error committing %s transaction: %w",
+	"err.aborted":            "aborted by user",
+	"err.target_exists":      "refusing to overwrite existing output",
+	"err.table_not_found":    "table or view not found",
+	"err.column_not_found":   "column not found",
+	"err.permission_denied":  "permission denied",
+	"err.login_failed":       "login failed",
+	"err.database_not_found": "database not found or not accessible",
+
+	// CLI
+	"db.missing_params":       "missing required connection parameters: %s",
+	"db.pool_error":           "error creating connection pool: %v",
+	"db.connect_error":        "cannot connect to database: %w",
+	"db.connected":            "Connected to MSSQL successfully!",
+	"cli.interrupted":         "aborted by user (Ctrl-C)",
+	"cli.interrupted_notice":  "Aborted by user (Ctrl-C)",
+	"cli.invalid_progress":    "invalid progress mode %q (use auto, bar, plain or none)",
+	"hint.table_not_found":    "check that the table or view exists in the database and that its name is spelled correctly. if it belongs to another schema, use the full name (for example: schema.table)",
+	"hint.column_not_found":   "check the column names in the fields file; 'getmssql fields <table>' lists the available columns",
+	"hint.permission_denied":  "the login needs SELECT permission on the table (and VIEW DEFINITION to list its columns); ask a database administrator to grant it",
+	"hint.login_failed":       "check the user and password (--user/--password or MSSQL_USER/MSSQL_PASSWORD) and that SQL Server authentication is enabled",
+	"hint.database_not_found": "check the database name (--database or MSSQL_DATABASE) and that the login has access to it",
+}
+
+var es = map[string]string{
+	"i18n.unsupported": "idioma no soportado %q (disponibles: %s)",
+
+	// Prompts
+	"prompt.confirm": "%s. %s (s/N): ",
+	"prompt.yes":     "s",
+	"prompt.aborted": "Cancelado por el usuario.",
+
+	// Progress
+	"progress.start_total":      "Iniciando descarga de la tabla '%s'... (filas totales: %d)",
+	"progress.start":            "Iniciando descarga de la tabla '%s'...",
+	"progress.bar":              "%5.1f%%  %d/%d filas  %.0f filas/s  restante %s",
+	"progress.bar_rows":         "Descargadas %d filas  %.0f filas/s",
+	"progress.line_start_total": "Iniciando descarga de la tabla '%s' (filas totales: %d)",
+	"progress.line_start":       "Iniciando descarga de la tabla '%s'",
+	"progress.line":             "Descargadas %d/%d filas (%.1f%%), %.0f filas/s, restante %s",
+	"progress.line_rows":        "Descargadas %d filas, %.0f filas/s",
+	"progress.stopped":          "Descarga detenida tras %d filas",
+	"progress.done":             "Total de filas descargadas: %d",
+
+	// Listing tables and fields
+	"tables.header":              "Tablas en la base de datos:",
+	"tables.query_error":         "error al consultar las tablas: %w",
+	"tables.scan_error":          "error al leer el nombre de la tabla: %w",
+	"fields.header":              "Campos de la tabla '%s':",
+	"fields.columns":             "Columna\tTipo\tAdmite nulos",
+	"fields.query_error":         "error al consultar los campos: %w",
+	"fields.scan_error":          "error al leer el campo: %w",
+	"fields.none_found":          "no se encontraron campos para la tabla '%s': %w",
+	"rows.error":                 "error al leer las filas: %w",
+	"rows.scan_error":            "error al leer la fila: %w",
+	"rows.row_error":             "fila %d: %v",
+	"rows.skipped":               "aviso: se omite %v",
+	"rows.invalid_policy":        "política de errores de fila no válida %q (usa fail, skip o log)",
+	"rejects.create_error":       "error al crear el archivo de rechazos: %w",
+	"rejects.write_error":        "error al escribir el archivo de rechazos: %w",
+	"values.bad_uuid":            "el uniqueidentifier tiene %d bytes, se esperaban 16",
+	"values.column_error":        "columna %s: %w",
+	"values.bad_datetime_format": "formato de fecha y hora no válido %q (usa iso, date o un formato de Go como 2006-01-02 15:04:05)",
+	"values.bad_timezone":        "zona horaria no válida %q: %v",
+	"spatial.bad_wkt":            "WKT no válido en la posición %d: %q",
+	"spatial.unsupported_type":   "%s no se puede escribir como GeoJSON",
+
+	// Downloading
+	"download.count_error":        "no se pudo obtener el total de filas: %w",
+	"download.query_error":        "error al consultar las filas de la tabla: %w",
+	"download.columns_error":      "error al obtener las columnas: %w",
+	"download.cancelled":          "exportación cancelada: %w",
+	"download.table_name_parts":   "el nombre de tabla %q tiene más partes que un esquema y un nombre; conéctate a su base de datos y usa esquema.tabla",
+	"download.done":               "Datos de la tabla '%s' escritos en %s en %s",
+	"download.rejected":           "%d filas rechazadas, ver %s",
+	"download.rejected_count":     "%d filas rechazadas",
+	"fieldsfile.read_error":       "error al leer el archivo de campos: %w",
+	"fieldsfile.empty":            "no se encontraron campos en el archivo: %s",
+	"typesfile.read_error":        "error al leer el archivo de tipos: %w",
+	"typesfile.parse_error":       "error al analizar el archivo de tipos %s: %v",
+	"typesfile.bad_type":          "archivo de tipos: columna %s: tipo desconocido %q (usa string, int, decimal, date, datetime o boolean)",
+	"typesfile.bad_option":        "archivo de tipos: columna %s: %s no se aplica al tipo %s",
+	"typesfile.bad_style":         "archivo de tipos: columna %s: estilo booleano no válido %q (usa un texto verdadero/falso como sí/no)",
+	"typesfile.bad_precision":     "archivo de tipos: columna %s: precisión %d y escala %d decimales no válidas",
+	"typesfile.column_error":      "archivo de tipos: columna %s: %w",
+	"typesfile.unknown_column":    "archivo de tipos: la columna %s no se exporta",
+	"typesfile.bad_value":         "no se puede convertir %q a %s",
+	"format.unknown":              "formato desconocido %q (disponibles: %s)",
+	"overwrite.invalid":           "política de sobrescritura no válida %q (usa prompt, always o never)",
+	"types.invalid_mode":          "tipos de columna no válidos %q (usa native o text)",
+	"binary.invalid_encoding":     "codificación binaria no válida %q (usa auto, base64 o hex)",
+	"csv.unknown_dialect":         "dialecto CSV desconocido %q (disponibles: %s)",
+	"csv.bad_delimiter":           "delimitador CSV no válido %q (usa un carácter que no sea un salto de línea)",
+	"csv.bad_quote":               "comilla CSV no válida %q (usa un carácter distinto del delimitador y de un salto de línea)",
+	"csv.quote_conflict":          "un dialecto CSV no puede entrecomillar todos los campos y ninguno a la vez",
+	"csv.bad_line_terminator":     "fin de línea CSV no válido %q (usa lf o crlf)",
+	"tsv.invalid_escaping":        "escape TSV no válido %q (usa text o iana)",
+	"tsv.unescapable":             "el TSV de IANA no admite tabuladores ni saltos de línea",
+	"parquet.invalid_compression": "compresión Parquet no válida %q (usa snappy, zstd, gzip o none)",
+	"arrow.bad_value":             "no se puede guardar %v como %v",
+	"arrow.null_value":            "NULL en una columna que no admite nulos",
+	"xlsx.cell_too_long":          "texto de más de los %d caracteres de una celda de Excel",
+	"sql.invalid_dialect":         "dialecto SQL no válido %q (use mssql, postgres, mysql o sqlite)",
+	"sql.bad_insert_rows":         "número de filas por INSERT no válido %d para %s (SQL Server admite como máximo 1000)",
+	"sql.nul_character":           "el texto de %s no admite el carácter NUL",
+	"avro.invalid_codec":          "códec Avro no válido %q (use deflate, snappy, zstd o none)",
+	"avro.bad_value":              "no se puede guardar %v como Avro %v",
+	"avro.null_value":             "NULL en una columna que no admite nulos",
+
+	// Writers
+	"writer.no_columns":      "la lista de columnas está vacía",
+	"writer.empty_column":    "el nombre de la columna en la posición %d está vacío",
+	"file.exists":            "El archivo '%s' ya existe",
+	"file.overwrite":         "¿Sobrescribir?",
+	"file.create_error":      "error al crear el archivo de salida: %w",
+	"file.write_error":       "error al escribir el archivo de salida: %w",
+	"db.open_error":          "error al abrir la base de datos %s: %w",
+	"db.exists_check_error":  "error al comprobar si la tabla existe en %s: %w",
+	"db.table_exists":        "La tabla '%s' ya existe en %s",
+	"db.recreate":            "¿Eliminarla y volver a crearla?",
+	"db.drop_error":          "error al eliminar la tabla en %s: %w",
+	"db.table_dropped":       "Tabla '%s' eliminada.",
+	"db.create_error":        "error al crear la tabla en %s: %w",
+	"db.begin_error":         "error al iniciar la transacción de %s: %w",
+	"db.prepare_error":       "error al preparar la sentencia de %s: %w",
+	"db.insert_error":        "error al insertar una fila en %s: %w",
+	"db.commit_error":        "error al confirmar la transacción de %s: %w",
+	"err.aborted":            "cancelado por el usuario",
+	"err.target_exists":      "no se sobrescribe la salida existente",
+	"err.table_not_found":    "tabla o vista no encontrada",
+	"err.column_not_found":   "columna no encontrada",
+	"err.permission_denied":  "permiso denegado",
+	"err.login_failed":       "inicio de sesión fallido",
+	"err.database_not_found": "base de datos no encontrada o sin acceso",
+
+	// CLI
+	"db.missing_params":       "faltan parámetros de conexión obligatorios: %s",
+	"db.pool_error":           "error al crear el pool de conexiones: %v",
+	"db.connect_error":        "no se puede conectar a la base de datos: %w",
+	"db.connected":            "¡Conectado a MSSQL correctamente!",
+	"cli.interrupted":         "cancelado por el usuario (Ctrl-C)",
+	"cli.interrupted_notice":  "Cancelado por el usuario (Ctrl-C)",
+	"cli.invalid_progress":    "modo de progreso no válido %q (usa auto, bar, plain o none)",
+	"hint.table_not_found":    "verifica que el nombre de la tabla o vista exista en la base de datos y esté correctamente escrito. si pertenece a otro esquema, usa el nombre completo (por ejemplo: esquema.tabla)",
+	"hint.column_not_found":   "revisa los nombres de columna del archivo de campos; 'getmssql fields <tabla>' muestra las columnas disponibles",
+	"hint.permission_denied":  "el usuario necesita permiso SELECT sobre la tabla (y VIEW DEFINITION para ver sus columnas); pide a un administrador de la base de datos que lo conceda",
+	"hint.login_failed":       "revisa el usuario y la contraseña (--user/--password o MSSQL_USER/MSSQL_PASSWORD) y que la autenticación de SQL Server esté habilitada",
+	"hint.database_not_found": "revisa el nombre de la base de datos (--database o MSSQL_DATABASE) y que el usuario tenga acceso a ella",
+}
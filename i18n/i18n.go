@@ -0,0 +1,127 @@
+// Package i18n holds the translated user-facing messages of getmssql.
+//
+// Messages are looked up by key in the catalog of the current language and
+// formatted with fmt. Keys missing from a catalog fall back to English, and
+// keys missing from every catalog are returned as is.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// English is the default language.
+const English = "en"
+
+var (
+	mu      sync.RWMutex
+	current = English
+)
+
+// Languages returns the sorted codes of all available languages.
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SetLanguage selects the language for all later messages. It accepts a bare
+// code ("es") or a locale name ("es_ES.UTF-8"); "C" and "POSIX" select English.
+func SetLanguage(lang string) error {
+	code, ok := normalize(lang)
+	if !ok {
+		return fmt.Errorf(T("i18n.unsupported"), lang, strings.Join(Languages(), ", "))
+	}
+	mu.Lock()
+	current = code
+	mu.Unlock()
+	return nil
+}
+
+// Language returns the code of the current language.
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Detect returns the language selected by the locale environment variables,
+// read through getenv (normally os.Getenv). As in POSIX, the first of LC_ALL,
+// LC_MESSAGES and LANG that is set wins. Unsupported locales yield English.
+func Detect(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := getenv(name); v != "" {
+			if code, ok := normalize(v); ok {
+				return code
+			}
+			return English
+		}
+	}
+	return English
+}
+
+// normalize turns a locale name into a catalog code.
+func normalize(lang string) (string, bool) {
+	code := strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(code, "_-.@"); i >= 0 {
+		code = code[:i]
+	}
+	if code == "c" || code == "posix" {
+		return English, true
+	}
+	_, ok := catalogs[code]
+	return code, ok
+}
+
+// lookup returns the message for key in the current language.
+func lookup(key string) string {
+	if msg, ok := catalogs[Language()][key]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[English][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// T returns the message for key in the current language, formatted with args.
+func T(key string, args ...interface{}) string {
+	msg := lookup(key)
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Errorf is like fmt.Errorf with the message for key as the format, so a
+// translated message can still wrap an error with %w.
+func Errorf(key string, args ...interface{}) error {
+	msg := lookup(key)
+	return fmt.Errorf(msg, args...)
+}
+
+// Error is an error whose message is translated each time it is printed.
+// It is meant for package-level sentinel errors, which are created before the
+// language is known; compare them with errors.Is as usual.
+type Error struct {
+	key string
+}
+
+// NewError returns a sentinel error with the message for key.
+func NewError(key string) *Error {
+	return &Error{key: key}
+}
+
+func (e *Error) Error() string { return lookup(e.key) }
+
+// IsYes reports whether answer confirms a (y/N) prompt. "y" is always
+// accepted, along with the current language's own yes answer.
+func IsYes(answer string) bool {
+	a := strings.ToLower(strings.TrimSpace(answer))
+	return a == "y" || a == lookup("prompt.yes")
+}
@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var verbRe = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs_MatchEnglish(t *testing.T) {
+	for lang, msgs := range catalogs {
+		for key, msg := range msgs {
+			ref, ok := en[key]
+			if !ok {
+				t.Errorf("%s: key %q is not in the English catalog", lang, key)
+				continue
+			}
+			// Translations must take the same arguments in the same order
+			if got, want := verbTypes(msg), verbTypes(ref); got != want {
+				t.Errorf("%s: key %q has verbs %q, English has %q", lang, key, got, want)
+			}
+		}
+		if lang != English && len(msgs) != len(en) {
+			for key := range en {
+				if _, ok := msgs[key]; !ok {
+					t.Errorf("%s: missing translation for %q", lang, key)
+				}
+			}
+		}
+	}
+}
+
+// verbTypes returns the conversion characters of the verbs in msg, ignoring flags and width.
+func verbTypes(msg string) string {
+	var b strings.Builder
+	for _, v := range verbRe.FindAllString(msg, -1) {
+		b.WriteByte(v[len(v)-1])
+	}
+	return b.String()
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{}, "en"},
+		{map[string]string{"LANG": "es_ES.UTF-8"}, "es"},
+		{map[string]string{"LANG": "es_MX"}, "es"},
+		{map[string]string{"LANG": "fr_FR.UTF-8"}, "en"},
+		{map[string]string{"LANG": "C"}, "en"},
+		{map[string]string{"LANG": "es_ES.UTF-8", "LC_MESSAGES": "en_US.UTF-8"}, "en"},
+		{map[string]string{"LANG": "en_US.UTF-8", "LC_ALL": "es_AR.UTF-8"}, "es"},
+	}
+	for _, c := range cases {
+		got := Detect(func(name string) string { return c.env[name] })
+		if got != c.want {
+			t.Errorf("Detect(%v) = %q, want %q", c.env, got, c.want)
+		}
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	defer SetLanguage(English)
+	if err := SetLanguage("es_ES.UTF-8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if Language() != "es" {
+		t.Errorf("expected es, got %q", Language())
+	}
+	if got := T("progress.done", 5); got != "Total de filas descargadas: 5" {
+		t.Errorf("unexpected Spanish message: %q", got)
+	}
+	err := SetLanguage("klingon")
+	if err == nil || !strings.Contains(err.Error(), "en, es") {
+		t.Errorf("expected unsupported language error, got: %v", err)
+	}
+	if Language() != "es" {
+		t.Error("expected a failed SetLanguage to keep the current language")
+	}
+}
+
+func TestT_Fallbacks(t *testing.T) {
+	defer SetLanguage(English)
+	SetLanguage("es")
+	delete(es, "db.connected")
+	defer func() { es["db.connected"] = "¡Conectado a MSSQL correctamente!" }()
+	if got := T("db.connected"); got != en["db.connected"] {
+		t.Errorf("expected English fallback, got %q", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("expected the key for unknown messages, got %q", got)
+	}
+}
+
+func TestErrorf_Wraps(t *testing.T) {
+	cause := errors.New("boom")
+	err := Errorf("rows.error", cause)
+	if !errors.Is(err, cause) || err.Error() != "row error: boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestError_TranslatedWhenPrinted(t *testing.T) {
+	defer SetLanguage(English)
+	sentinel := NewError("err.login_failed")
+	wrapped := Errorf("db.connect_error", sentinel)
+	SetLanguage("es")
+	if got := wrapped.Error(); got != "cannot connect to database: login failed" {
+		// Errorf formats eagerly; only the sentinel itself is lazy
+		t.Errorf("unexpected wrapped message: %q", got)
+	}
+	if got := sentinel.Error(); got != "inicio de sesión fallido" {
+		t.Errorf("expected Spanish sentinel message, got %q", got)
+	}
+	if !errors.Is(wrapped, sentinel) {
+		t.Error("expected errors.Is to match the sentinel")
+	}
+}
+
+func TestIsYes(t *testing.T) {
+	defer SetLanguage(English)
+	if !IsYes(" Y ") || IsYes("s") || IsYes("") {
+		t.Error("unexpected English answers")
+	}
+	SetLanguage("es")
+	if !IsYes("s") || !IsYes("y") || IsYes("n") {
+		t.Error("unexpected Spanish answers")
+	}
+}
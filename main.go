@@ -6,8 +6,10 @@
 //	  List all tables in the database
 //	go run main.go fields <table_name>
 //	  List all fields in the specified table
-//	go run main.go download [--fields <fields_file>] [--format <format>] <table_name>
-//	  Export data from the specified table. Format can be: json, tsv, csv, sqlite3, duckdb (default: json)
+//	go run main.go download [--fields <fields_file>] [--format <format>] [--output <path>] [--where <filter>] <table_name>
+//	  Export data from the specified table. Format can be: json, jsonl, geojson, csv, tsv, parquet,
+//	  arrow, avro, xlsx, sql, pgcopy, sqlite3, duckdb (default: json); download --help lists the
+//	  formats registered in the build
 
 package main
 